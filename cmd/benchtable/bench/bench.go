@@ -0,0 +1,212 @@
+/*
+Package bench parses go test -bench output and renders it as tables.
+It is the library behind the benchtable command.
+
+	sets, err := bench.Parse(os.Stdin)
+	if err != nil {
+		return err
+	}
+
+	var tables []*bench.Table
+	for _, set := range sets {
+		tables = append(tables, bench.NewTable(set, &bench.Options{}))
+	}
+	return bench.WriteTables(os.Stdout, bench.FormatMarkdown, tables)
+
+Parse returns one Set per package. A Set has Benchmarks, each of which
+has a Metric per Unit (e.g., ns/op). Config is the header of the
+output which describes the environment (goos, goarch, pkg and cpu).
+*/
+package bench
+
+import (
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Benchmark is a result of a benchmark. When the benchmark is run
+// multiple times (e.g., with -count=N), results of the same name are
+// grouped into one Benchmark.
+type Benchmark struct {
+	// Name is the name without the GOMAXPROCS suffix (e.g.,
+	// "BenchmarkSort1K/Quick" of "BenchmarkSort1K/Quick-4").
+	Name string
+
+	// Procs is GOMAXPROCS parsed from the suffix of the name. It is 1
+	// when the name has no suffix.
+	Procs int
+
+	// Runs is the number of result lines grouped into this benchmark.
+	Runs int
+
+	// Iterations is the total number of iterations of all runs.
+	Iterations int
+
+	// Metrics maps a unit to its measurement.
+	Metrics map[Unit]*Metric
+}
+
+// FullName returns the name with the GOMAXPROCS suffix as printed by
+// go test.
+func (b *Benchmark) FullName() string {
+	if b.Procs <= 1 {
+		return b.Name
+	}
+	return b.Name + "-" + strconv.Itoa(b.Procs)
+}
+
+// IterationsPerRun returns the mean number of iterations of a run.
+func (b *Benchmark) IterationsPerRun() int {
+	if b.Runs == 0 {
+		return 0
+	}
+	return int(math.Round(float64(b.Iterations) / float64(b.Runs)))
+}
+
+// Unit is the unit of a metric (e.g., "ns/op", "MB/s" or a custom unit
+// reported by b.ReportMetric).
+type Unit string
+
+// HigherIsBetter reports whether a larger value of the unit is better.
+// It is true for throughput units like MB/s.
+func (u Unit) HigherIsBetter() bool {
+	return strings.HasSuffix(string(u), "/s")
+}
+
+// Metric is the measurement of a unit over all runs of a benchmark.
+type Metric struct {
+	Unit Unit
+
+	// Samples are the raw values of each run.
+	Samples []float64
+
+	// Value is the summary (mean or median) of samples without outliers
+	// and Spread is the largest deviation of those samples from Value
+	// in percent. They are set by Set.Summarize.
+	Value  float64
+	Spread float64
+
+	// Outliers is the number of samples removed as outliers.
+	Outliers int
+}
+
+// Config is a key-value line printed in the header of go test output
+// (e.g., "goos: linux" or "pkg: github.com/tcnksm/misc").
+type Config struct {
+	Key   string
+	Value string
+}
+
+// Set is the collection of benchmark results of a package.
+type Set struct {
+	// Package is the value of the "pkg:" line. It is empty when the
+	// output does not have the line.
+	Package string
+
+	// Config is the header lines which describe the environment
+	// (goos, goarch, pkg, cpu and so on) in the order they appear.
+	Config []*Config
+
+	Benchmarks []*Benchmark
+
+	// Units is the union of units reported by benchmarks in the
+	// order they first appear.
+	Units []Unit
+
+	index map[string]*Benchmark
+
+	// parent is the set which this set is filtered from.
+	parent *Set
+}
+
+// add merges a single result line into the benchmark with the same name.
+func (s *Set) add(r *result) {
+	if s.index == nil {
+		s.index = make(map[string]*Benchmark)
+	}
+
+	b, ok := s.index[r.fullName]
+	if !ok {
+		b = &Benchmark{
+			Name:    r.name,
+			Procs:   r.procs,
+			Metrics: make(map[Unit]*Metric),
+		}
+		s.index[r.fullName] = b
+		s.Benchmarks = append(s.Benchmarks, b)
+	}
+
+	b.Runs++
+	b.Iterations += r.iterations
+	for i, u := range r.units {
+		m, ok := b.Metrics[u]
+		if !ok {
+			m = &Metric{Unit: u}
+			b.Metrics[u] = m
+		}
+		m.Samples = append(m.Samples, r.values[i])
+	}
+
+	s.addUnits(r.units)
+}
+
+func (s *Set) addUnits(units []Unit) {
+	for _, u := range units {
+		found := false
+		for _, su := range s.Units {
+			if su == u {
+				found = true
+				break
+			}
+		}
+		if !found {
+			s.Units = append(s.Units, u)
+		}
+	}
+}
+
+// Lookup returns the benchmark of the given full name (with the
+// GOMAXPROCS suffix) or nil.
+func (s *Set) Lookup(fullName string) *Benchmark {
+	return s.index[fullName]
+}
+
+// Filter returns the set of benchmarks for which keep returns true.
+// Benchmarks are shared with the original set.
+func (s *Set) Filter(keep func(b *Benchmark) bool) *Set {
+	fs := &Set{
+		Package: s.Package,
+		Config:  s.Config,
+		index:   make(map[string]*Benchmark),
+		parent:  s,
+	}
+	for _, b := range s.Benchmarks {
+		if !keep(b) {
+			continue
+		}
+		fs.Benchmarks = append(fs.Benchmarks, b)
+		fs.index[b.FullName()] = b
+	}
+
+	// Keep the order of units of the original set
+	for _, u := range s.Units {
+		for _, b := range fs.Benchmarks {
+			if _, ok := b.Metrics[u]; ok {
+				fs.Units = append(fs.Units, u)
+				break
+			}
+		}
+	}
+	return fs
+}
+
+// Caption returns the description of the environment of the set
+// (e.g., "goos: linux, goarch: amd64, pkg: sort").
+func (s *Set) Caption() string {
+	items := make([]string, 0, len(s.Config))
+	for _, c := range s.Config {
+		items = append(items, c.Key+": "+c.Value)
+	}
+	return strings.Join(items, ", ")
+}
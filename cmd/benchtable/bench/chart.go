@@ -0,0 +1,327 @@
+package bench
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Chart is a horizontal bar chart of a metric. Bars are grouped by
+// sub-benchmark group and each bar has a value per series (e.g., old
+// and new in compare mode).
+type Chart struct {
+	Title  string
+	Unit   Unit
+	Series []string
+	Groups []*BarGroup
+
+	column *column
+}
+
+// BarGroup is a group of bars (e.g., sub-benchmarks of BenchmarkSort1K).
+type BarGroup struct {
+	Name string
+	Bars []*Bar
+}
+
+// Bar is a bar of a benchmark. Values has a value per series and NaN
+// means the benchmark does not exist in the series.
+type Bar struct {
+	Label  string
+	Values []float64
+}
+
+// max returns the largest value in the chart.
+func (c *Chart) max() float64 {
+	max := 0.0
+	for _, g := range c.Groups {
+		for _, b := range g.Bars {
+			for _, v := range b.Values {
+				if !math.IsNaN(v) && v > max {
+					max = v
+				}
+			}
+		}
+	}
+	return max
+}
+
+// add adds the value of the benchmark in the i-th series.
+func (c *Chart) add(b *Benchmark, i int, procs bool) {
+	m, ok := b.Metrics[c.Unit]
+	if !ok {
+		return
+	}
+
+	name, label := "", b.Name
+	if j := strings.LastIndex(b.Name, "/"); j >= 0 {
+		name, label = b.Name[:j], b.Name[j+1:]
+	}
+	if procs {
+		label += "-" + strconv.Itoa(b.Procs)
+	}
+
+	var g *BarGroup
+	for _, cg := range c.Groups {
+		if cg.Name == name {
+			g = cg
+			break
+		}
+	}
+	if g == nil {
+		g = &BarGroup{Name: name}
+		c.Groups = append(c.Groups, g)
+	}
+
+	var bar *Bar
+	for _, gb := range g.Bars {
+		if gb.Label == label {
+			bar = gb
+			break
+		}
+	}
+	if bar == nil {
+		bar = &Bar{Label: label, Values: make([]float64, len(c.Series))}
+		for j := range bar.Values {
+			bar.Values[j] = math.NaN()
+		}
+		g.Bars = append(g.Bars, bar)
+	}
+	bar.Values[i] = m.Value
+}
+
+// NewChart builds the chart of the metric of the given unit.
+func NewChart(set *Set, unit Unit, opts *Options) *Chart {
+	title, procs := caption(set)
+	c := &Chart{
+		Title:  title,
+		Unit:   unit,
+		Series: []string{""},
+		column: newColumn(unit, metricValues(unit, set), opts),
+	}
+	for _, b := range set.Benchmarks {
+		c.add(b, 0, procs)
+	}
+	return c
+}
+
+// NewCompareChart builds the chart which shows the metric of the given
+// unit of old and new side by side.
+func NewCompareChart(old, new *Set, unit Unit, opts *Options) *Chart {
+	title, procs := caption(new, old)
+	c := &Chart{
+		Title:  title,
+		Unit:   unit,
+		Series: []string{"old", "new"},
+		column: newColumn(unit, metricValues(unit, old, new), opts),
+	}
+	for _, cmp := range Compare(old, new) {
+		if cmp.Old != nil {
+			c.add(cmp.Old, 0, procs)
+		}
+		if cmp.New != nil {
+			c.add(cmp.New, 1, procs)
+		}
+	}
+	return c
+}
+
+// Chart kinds.
+const (
+	ChartText = "text"
+	ChartSVG  = "svg"
+)
+
+// textWidth is the length of the longest bar of text charts.
+const textWidth = 40
+
+// WriteCharts writes charts in the given kind (text or svg).
+func WriteCharts(w io.Writer, kind string, charts []*Chart) error {
+	switch kind {
+	case ChartText:
+		return WriteTextCharts(w, charts, textWidth)
+	case ChartSVG:
+		return WriteSVG(w, charts)
+	}
+	return fmt.Errorf("unknown chart %q", kind)
+}
+
+// blocks are partial blocks in eighths used for the end of text bars.
+var blocks = []rune(" ▏▎▍▌▋▊▉")
+
+// textBar draws the bar of the value whose length is width when value
+// is max.
+func textBar(v, max float64, width int) string {
+	if max <= 0 || v <= 0 {
+		return ""
+	}
+	eighths := int(math.Round(v / max * float64(width*8)))
+	return strings.Repeat("█", eighths/8) + strings.TrimSpace(string(blocks[eighths%8]))
+}
+
+// WriteTextCharts writes charts as horizontal bars with Unicode block
+// characters for terminals. width is the length of the longest bar.
+func WriteTextCharts(w io.Writer, charts []*Chart, width int) error {
+	for i, c := range charts {
+		if i > 0 {
+			if _, err := fmt.Fprintln(w); err != nil {
+				return err
+			}
+		}
+		if c.Title != "" {
+			if _, err := fmt.Fprintln(w, c.Title); err != nil {
+				return err
+			}
+		}
+
+		labelWidth, seriesWidth := 0, 0
+		for _, g := range c.Groups {
+			for _, b := range g.Bars {
+				if n := len([]rune(b.Label)); n > labelWidth {
+					labelWidth = n
+				}
+			}
+		}
+		for _, s := range c.Series {
+			if len(s) > seriesWidth {
+				seriesWidth = len(s)
+			}
+		}
+
+		max := c.max()
+		for _, g := range c.Groups {
+			name := g.Name
+			if name == "" {
+				name = "benchmarks"
+			}
+			if _, err := fmt.Fprintf(w, "%s (%s)\n", name, c.column.header()); err != nil {
+				return err
+			}
+
+			for _, b := range g.Bars {
+				for j, v := range b.Values {
+					label := ""
+					if j == 0 {
+						label = b.Label
+					}
+
+					bar, value := "", ""
+					if !math.IsNaN(v) {
+						bar, value = textBar(v, max, width), c.column.value(v)
+					}
+
+					line := fmt.Sprintf("  %-*s ", labelWidth, label)
+					if seriesWidth > 0 {
+						line += fmt.Sprintf("%-*s ", seriesWidth, c.Series[j])
+					}
+					line += fmt.Sprintf("%-*s %s", width+1, bar, value)
+					if _, err := fmt.Fprintln(w, strings.TrimRight(line, " ")); err != nil {
+						return err
+					}
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// Layout of SVG charts in pixels.
+const (
+	svgBarWidth  = 400
+	svgBarHeight = 16
+	svgGap       = 4
+	svgCharWidth = 7
+	svgMargin    = 10
+)
+
+// svgColors are fill colors of series.
+var svgColors = []string{"#4e79a7", "#f28e2b", "#59a14f", "#e15759"}
+
+func svgEscape(s string) string {
+	var b strings.Builder
+	xml.EscapeText(&b, []byte(s))
+	return b.String()
+}
+
+// WriteSVG writes charts as a self-contained SVG document. Charts are
+// stacked vertically.
+func WriteSVG(w io.Writer, charts []*Chart) error {
+	labelWidth := 0
+	for _, c := range charts {
+		for _, g := range c.Groups {
+			for _, b := range g.Bars {
+				if n := len([]rune(b.Label)); n > labelWidth {
+					labelWidth = n
+				}
+			}
+		}
+	}
+	labelWidth = (labelWidth + 1) * svgCharWidth
+	valueWidth := 12 * svgCharWidth
+
+	var body strings.Builder
+	y := svgMargin
+	for _, c := range charts {
+		if c.Title != "" {
+			y += svgBarHeight
+			fmt.Fprintf(&body, "<text x=\"%d\" y=\"%d\" font-weight=\"bold\">%s</text>\n",
+				svgMargin, y, svgEscape(c.Title))
+		}
+
+		if len(c.Series) > 1 {
+			y += svgGap
+			x := svgMargin
+			for i, s := range c.Series {
+				fmt.Fprintf(&body, "<rect x=\"%d\" y=\"%d\" width=\"%d\" height=\"%d\" fill=\"%s\"/>\n",
+					x, y+2, svgBarHeight-4, svgBarHeight-4, svgColors[i%len(svgColors)])
+				fmt.Fprintf(&body, "<text x=\"%d\" y=\"%d\">%s</text>\n",
+					x+svgBarHeight, y+svgBarHeight-4, svgEscape(s))
+				x += svgBarHeight + (len(s)+2)*svgCharWidth
+			}
+			y += svgBarHeight
+		}
+
+		max := c.max()
+		for _, g := range c.Groups {
+			name := g.Name
+			if name == "" {
+				name = "benchmarks"
+			}
+			y += svgBarHeight + svgGap
+			fmt.Fprintf(&body, "<text x=\"%d\" y=\"%d\" font-style=\"italic\">%s (%s)</text>\n",
+				svgMargin, y, svgEscape(name), svgEscape(c.column.header()))
+
+			for _, b := range g.Bars {
+				y += svgGap
+				fmt.Fprintf(&body, "<text x=\"%d\" y=\"%d\" text-anchor=\"end\">%s</text>\n",
+					svgMargin+labelWidth, y+svgBarHeight*len(b.Values)/2+4, svgEscape(b.Label))
+
+				for i, v := range b.Values {
+					if !math.IsNaN(v) {
+						width := 0.0
+						if max > 0 {
+							width = v / max * svgBarWidth
+						}
+						x := svgMargin + labelWidth + svgGap
+						fmt.Fprintf(&body, "<rect x=\"%d\" y=\"%d\" width=\"%.1f\" height=\"%d\" fill=\"%s\"/>\n",
+							x, y, width, svgBarHeight-2, svgColors[i%len(svgColors)])
+						fmt.Fprintf(&body, "<text x=\"%.1f\" y=\"%d\">%s</text>\n",
+							float64(x)+width+svgGap, y+svgBarHeight-4, svgEscape(c.column.value(v)))
+					}
+					y += svgBarHeight
+				}
+			}
+		}
+		y += svgMargin
+	}
+
+	width := svgMargin*2 + labelWidth + svgGap + svgBarWidth + valueWidth
+	_, err := fmt.Fprintf(w, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" font-family="monospace" font-size="12">
+<rect width="100%%" height="100%%" fill="white"/>
+%s</svg>
+`, width, y+svgMargin, body.String())
+	return err
+}
@@ -0,0 +1,135 @@
+package bench
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Thresholds maps a unit to the allowed regression in percent. The
+// empty unit is the default for units which are not in the map.
+type Thresholds map[Unit]float64
+
+// ParseThresholds parses a comma separated list of UNIT=PERCENT (e.g.,
+// "ns/op=5,allocs/op=0"). A PERCENT without unit is the default for
+// all other units.
+func ParseThresholds(s string) (Thresholds, error) {
+	th := make(Thresholds)
+	for _, item := range strings.Split(s, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+
+		unit, pct := "", item
+		if i := strings.LastIndex(item, "="); i >= 0 {
+			unit, pct = item[:i], item[i+1:]
+		}
+
+		v, err := strconv.ParseFloat(strings.TrimSuffix(pct, "%"), 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid threshold %q: %s", item, err)
+		}
+		th[Unit(unit)] = v
+	}
+	return th, nil
+}
+
+// lookup returns the threshold of the unit. It returns false when the
+// unit is not checked.
+func (th Thresholds) lookup(unit Unit) (float64, bool) {
+	if v, ok := th[unit]; ok {
+		return v, true
+	}
+	v, ok := th[""]
+	return v, ok
+}
+
+// regression returns how much worse new is than old in percent. It is
+// negative when new is better.
+func regression(unit Unit, old, new float64) float64 {
+	d := delta(old, new)
+	if unit.HigherIsBetter() {
+		return -d
+	}
+	return d
+}
+
+// isRegression reports whether the change of the metric from old to new
+// exceeds the threshold of the unit. A change which is not significant
+// at level alpha is not a regression. When there are too few runs for
+// the test to be significant at level alpha, only the threshold is
+// checked.
+func isRegression(unit Unit, old, new *Metric, th Thresholds, alpha float64) bool {
+	limit, ok := th.lookup(unit)
+	if !ok {
+		return false
+	}
+	if regression(unit, old.Value, new.Value) <= limit {
+		return false
+	}
+	if p, ok := significance(old, new); ok && p >= alpha && conclusive(old, new, alpha) {
+		return false
+	}
+	return true
+}
+
+// Violation is a metric which regresses more than its threshold.
+type Violation struct {
+	Package   string
+	Name      string
+	Procs     int
+	Unit      Unit
+	Old       float64
+	New       float64
+	Threshold float64
+}
+
+func (v *Violation) String() string {
+	name := v.Name
+	if v.Procs > 1 {
+		name += "-" + strconv.Itoa(v.Procs)
+	}
+	if v.Package != "" {
+		name = v.Package + "." + name
+	}
+	return fmt.Sprintf("%s: %s %s -> %s (%s, threshold %s%%)", name, v.Unit,
+		formatValue(round(v.Old)), formatValue(round(v.New)),
+		formatDelta(delta(v.Old, v.New)), formatValue(v.Threshold))
+}
+
+// Check compares old (baseline) and new sets and returns metrics which
+// regress more than the thresholds. Benchmarks on only one side are
+// not checked.
+func Check(old, new []*Set, th Thresholds, alpha float64) []*Violation {
+	var vs []*Violation
+	for _, pair := range PairSets(old, new) {
+		units := mergeUnits(pair[0], pair[1])
+		for _, c := range Compare(pair[0], pair[1]) {
+			if c.Old == nil || c.New == nil {
+				continue
+			}
+			for _, u := range units {
+				om, nm := c.Old.Metrics[u], c.New.Metrics[u]
+				if om == nil || nm == nil {
+					continue
+				}
+				if !isRegression(u, om, nm, th, alpha) {
+					continue
+				}
+
+				limit, _ := th.lookup(u)
+				vs = append(vs, &Violation{
+					Package:   pair[1].Package,
+					Name:      c.Name,
+					Procs:     c.Procs,
+					Unit:      u,
+					Old:       om.Value,
+					New:       nm.Value,
+					Threshold: limit,
+				})
+			}
+		}
+	}
+	return vs
+}
@@ -0,0 +1,212 @@
+package bench
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+)
+
+// Comparison is a pair of benchmark results with the same name
+// from the old and the new run. Old or New is nil when the benchmark
+// exists on only one side.
+type Comparison struct {
+	Name  string
+	Procs int
+	Old   *Benchmark
+	New   *Benchmark
+}
+
+// Compare pairs benchmarks in old and new by name and GOMAXPROCS. Benchmarks are
+// ordered as they appear in old, followed by ones only in new.
+func Compare(old, new *Set) []*Comparison {
+	cmps := make([]*Comparison, 0, len(old.Benchmarks))
+	for _, b := range old.Benchmarks {
+		cmps = append(cmps, &Comparison{
+			Name:  b.Name,
+			Procs: b.Procs,
+			Old:   b,
+			New:   new.Lookup(b.FullName()),
+		})
+	}
+
+	for _, b := range new.Benchmarks {
+		if old.Lookup(b.FullName()) != nil {
+			continue
+		}
+		cmps = append(cmps, &Comparison{Name: b.Name, Procs: b.Procs, New: b})
+	}
+
+	return cmps
+}
+
+// PairSets pairs sets in old and new by package. Sets are ordered as
+// they appear in old, followed by ones only in new. A set on only one
+// side is paired with an empty set of the same package.
+func PairSets(old, new []*Set) [][2]*Set {
+	pairs := make([][2]*Set, 0, len(old))
+	index := make(map[string]int)
+	for _, s := range old {
+		index[s.Package] = len(pairs)
+		pairs = append(pairs, [2]*Set{s, {Package: s.Package, Config: s.Config}})
+	}
+
+	for _, s := range new {
+		i, ok := index[s.Package]
+		if !ok {
+			index[s.Package] = len(pairs)
+			pairs = append(pairs, [2]*Set{{Package: s.Package, Config: s.Config}, s})
+			continue
+		}
+		pairs[i][1] = s
+	}
+	return pairs
+}
+
+// mergeUnits returns the union of units of old and new.
+func mergeUnits(old, new *Set) []Unit {
+	s := &Set{}
+	s.addUnits(old.Units)
+	s.addUnits(new.Units)
+	return s.Units
+}
+
+// delta returns the percentage change from old to new.
+func delta(old, new float64) float64 {
+	if old == 0 {
+		if new == 0 {
+			return 0
+		}
+		if new > 0 {
+			return math.Inf(1)
+		}
+		return math.Inf(-1)
+	}
+	return (new - old) / math.Abs(old) * 100
+}
+
+// formatDelta formats the percentage change (e.g., +12.30%).
+func formatDelta(d float64) string {
+	return fmt.Sprintf("%+.2f%%", d)
+}
+
+// significance returns the p-value of the Mann-Whitney U test on
+// samples of old and new without outliers. It returns false when either
+// metric does not have multiple samples.
+func significance(old, new *Metric) (float64, bool) {
+	if len(old.Samples) < 2 || len(new.Samples) < 2 {
+		return 0, false
+	}
+	return MannWhitneyU(removeOutliers(old.Samples), removeOutliers(new.Samples)), true
+}
+
+// conclusive reports whether the Mann-Whitney U test on samples of old
+// and new can be significant at level alpha at all. With few runs (e.g.,
+// 3 and 3 at 0.05), even samples which do not overlap are not, so the
+// result of the test says nothing about the change.
+func conclusive(old, new *Metric, alpha float64) bool {
+	return MinPValue(len(removeOutliers(old.Samples)), len(removeOutliers(new.Samples))) < alpha
+}
+
+// formatChange formats the change from old to new. When both metrics
+// have multiple samples, the Mann-Whitney U test is applied and the
+// change is shown as "~" if it is not significant at level alpha (and
+// the test can be significant with the number of runs).
+func formatChange(old, new *Metric, alpha float64) string {
+	d := formatDelta(delta(old.Value, new.Value))
+	p, ok := significance(old, new)
+	if !ok {
+		return d
+	}
+
+	if p >= alpha && conclusive(old, new, alpha) {
+		d = "~"
+	}
+	return fmt.Sprintf("%s (p=%.3f n=%d+%d)", d, p,
+		len(removeOutliers(old.Samples)), len(removeOutliers(new.Samples)))
+}
+
+// NewCompareTable builds the table which shows old value, new value and
+// percentage change of each metric per benchmark. Changes which regress
+// more than the thresholds are highlighted.
+func NewCompareTable(old, new *Set, opts *Options) *Table {
+	plain := opts.Plain
+	units := mergeUnits(old, new)
+	pvalue := plain && (hasSamples(old) || hasSamples(new))
+
+	title, procsColumn := caption(new, old)
+	t := &Table{Caption: title}
+	t.addColumns("name", "name")
+	if procsColumn {
+		t.addColumns("procs", "procs")
+	}
+	cols := make([]*column, len(units))
+	for i, u := range units {
+		cols[i] = newColumn(u, metricValues(u, old, new), opts)
+		name := cols[i].header()
+		if !plain {
+			t.addColumns(string(u), "old "+name, "new "+name, "delta")
+			continue
+		}
+
+		// Header names must be unique for spreadsheets
+		t.addColumns(string(u), "old "+name, "new "+name, "delta "+name)
+		if pvalue {
+			t.addColumns(string(u), "p "+string(u))
+		}
+	}
+
+	for _, c := range Compare(old, new) {
+		name := c.Name
+		switch {
+		case c.New == nil:
+			name += " (old only)"
+		case c.Old == nil:
+			name += " (new only)"
+		}
+
+		row := []string{name}
+		if procsColumn {
+			row = append(row, strconv.Itoa(c.Procs))
+		}
+		for i, u := range units {
+			var om, nm *Metric
+			if c.Old != nil {
+				om = c.Old.Metrics[u]
+			}
+			if c.New != nil {
+				nm = c.New.Metrics[u]
+			}
+
+			cells := []string{"", "", ""}
+			if pvalue {
+				cells = append(cells, "")
+			}
+			if om != nil {
+				cells[0] = cols[i].format(om)
+			}
+			if nm != nil {
+				cells[1] = cols[i].format(nm)
+			}
+			if om != nil && nm != nil {
+				if plain {
+					cells[2] = formatDelta(delta(om.Value, nm.Value))
+				} else {
+					cells[2] = formatChange(om, nm, opts.Alpha)
+				}
+				if opts.Thresholds != nil && isRegression(u, om, nm, opts.Thresholds, opts.Alpha) {
+					t.Mark(len(t.Rows), len(row)+2)
+				}
+				if p, ok := significance(om, nm); ok && pvalue {
+					cells[3] = fmt.Sprintf("%.3f", p)
+				}
+			}
+			row = append(row, cells...)
+		}
+		t.Rows = append(t.Rows, row)
+	}
+
+	if plain && new.Package != "" {
+		t.prependColumn("pkg", new.Package)
+	}
+	return t
+}
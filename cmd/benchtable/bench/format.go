@@ -0,0 +1,326 @@
+package bench
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"io"
+	"math"
+	"strings"
+)
+
+// Output formats.
+const (
+	FormatMarkdown = "markdown"
+	FormatCSV      = "csv"
+	FormatTSV      = "tsv"
+	FormatJSON     = "json"
+	FormatHTML     = "html"
+)
+
+// WriteTables writes the tables in the given format. JSON is not a
+// table format; use WriteJSON or WriteCompareJSON for it.
+func WriteTables(w io.Writer, format string, tables []*Table) error {
+	switch format {
+	case FormatMarkdown:
+		return writeMarkdown(w, tables)
+	case FormatCSV:
+		return writeCSV(w, ',', tables)
+	case FormatTSV:
+		return writeCSV(w, '\t', tables)
+	case FormatHTML:
+		return writeHTML(w, tables)
+	}
+	return fmt.Errorf("unknown format %q", format)
+}
+
+// writeMarkdown writes each table as a section which starts with its
+// caption.
+func writeMarkdown(w io.Writer, tables []*Table) error {
+	for i, t := range tables {
+		if i > 0 {
+			if _, err := fmt.Fprintln(w); err != nil {
+				return err
+			}
+		}
+		if t.Caption != "" {
+			if _, err := fmt.Fprintf(w, "%s\n\n", t.Caption); err != nil {
+				return err
+			}
+		}
+		if err := writeMarkdownTable(w, t); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func writeMarkdownTable(w io.Writer, t *Table) error {
+	if err := writeMarkdownRow(w, t.Header); err != nil {
+		return err
+	}
+
+	str := "| :"
+	for i := range t.Header {
+		if i == 0 {
+			str += "---: |"
+			continue
+		}
+		str += " ---: |"
+	}
+	if _, err := fmt.Fprintln(w, str); err != nil {
+		return err
+	}
+
+	for i, row := range t.Rows {
+		cells := make([]string, len(row))
+		for j, c := range row {
+			if t.Marked(i, j) && c != "" {
+				c = "**" + c + "**"
+			}
+			cells[j] = c
+		}
+		if err := writeMarkdownRow(w, cells); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func writeMarkdownRow(w io.Writer, row []string) error {
+	_, err := fmt.Fprintln(w, "| "+strings.Join(row, " | ")+" |")
+	return err
+}
+
+// writeCSV writes rows of all tables. The header is written again
+// (after an empty line) only when it differs from the previous one, so
+// packages which report the same units end up in one sheet.
+func writeCSV(w io.Writer, comma rune, tables []*Table) error {
+	cw := csv.NewWriter(w)
+	cw.Comma = comma
+
+	var prev []string
+	for i, t := range tables {
+		if i == 0 || !equalStrings(prev, t.Header) {
+			if i > 0 {
+				cw.Flush()
+				if _, err := fmt.Fprintln(w); err != nil {
+					return err
+				}
+			}
+			if err := cw.Write(t.Header); err != nil {
+				return err
+			}
+		}
+		if err := cw.WriteAll(t.Rows); err != nil {
+			return err
+		}
+		prev = t.Header
+	}
+
+	cw.Flush()
+	return cw.Error()
+}
+
+func equalStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+var htmlTmpl = template.Must(template.New("html").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>benchtable</title>
+<style>
+table { border-collapse: collapse; font-family: monospace; margin-bottom: 1em; }
+caption { text-align: left; padding: 4px 0; }
+th, td { border: 1px solid #ccc; padding: 4px 8px; text-align: right; }
+th:first-child, td:first-child { text-align: left; }
+td.marked { color: #c00; font-weight: bold; }
+</style>
+</head>
+<body>
+{{- range .}}
+<table>
+{{- if .Caption}}
+<caption>{{.Caption}}</caption>
+{{- end}}
+<thead>
+<tr>{{range .Header}}<th>{{.}}</th>{{end}}</tr>
+</thead>
+<tbody>
+{{- range .Rows}}
+<tr>{{range .}}<td{{if .Marked}} class="marked"{{end}}>{{.Text}}</td>{{end}}</tr>
+{{- end}}
+</tbody>
+</table>
+{{- end}}
+</body>
+</html>
+`))
+
+type htmlTable struct {
+	Caption string
+	Header  []string
+	Rows    [][]htmlCell
+}
+
+type htmlCell struct {
+	Text   string
+	Marked bool
+}
+
+func writeHTML(w io.Writer, tables []*Table) error {
+	data := make([]*htmlTable, 0, len(tables))
+	for _, t := range tables {
+		ht := &htmlTable{Caption: t.Caption, Header: t.Header}
+		for i, row := range t.Rows {
+			cells := make([]htmlCell, len(row))
+			for j, c := range row {
+				cells[j] = htmlCell{Text: c, Marked: t.Marked(i, j)}
+			}
+			ht.Rows = append(ht.Rows, cells)
+		}
+		data = append(data, ht)
+	}
+	return htmlTmpl.Execute(w, data)
+}
+
+// Record is the JSON representation of a benchmark.
+type Record struct {
+	Package    string           `json:"package,omitempty"`
+	Name       string           `json:"name"`
+	Procs      int              `json:"procs"`
+	Iterations int              `json:"iterations"` // per run
+	Runs       int              `json:"runs"`
+	Metrics    map[Unit]float64 `json:"metrics"`
+
+	// Spreads is set only when the benchmark is run multiple times.
+	Spreads map[Unit]float64 `json:"spreads,omitempty"`
+}
+
+func newRecord(pkg string, b *Benchmark) *Record {
+	if b == nil {
+		return nil
+	}
+
+	r := &Record{
+		Package:    pkg,
+		Name:       b.Name,
+		Procs:      b.Procs,
+		Iterations: b.IterationsPerRun(),
+		Runs:       b.Runs,
+		Metrics:    make(map[Unit]float64, len(b.Metrics)),
+	}
+	for u, m := range b.Metrics {
+		r.Metrics[u] = m.Value
+		if b.Runs > 1 {
+			if r.Spreads == nil {
+				r.Spreads = make(map[Unit]float64, len(b.Metrics))
+			}
+			r.Spreads[u] = m.Spread
+		}
+	}
+	return r
+}
+
+// ChangeRecord is the JSON representation of the change of a metric.
+type ChangeRecord struct {
+	// Delta is the percentage change. It is null when the old value is
+	// zero and the new one is not.
+	Delta *float64 `json:"delta"`
+
+	// P is the p-value of the Mann-Whitney U test and Significant is
+	// whether it is below alpha. They are set only when both sides have
+	// multiple samples.
+	P           *float64 `json:"p,omitempty"`
+	Significant *bool    `json:"significant,omitempty"`
+
+	// Regression is true when the change exceeds the threshold.
+	Regression bool `json:"regression,omitempty"`
+}
+
+// ComparisonRecord is the JSON representation of a comparison.
+type ComparisonRecord struct {
+	Package string                 `json:"package,omitempty"`
+	Name    string                 `json:"name"`
+	Procs   int                    `json:"procs"`
+	Old     *Record                `json:"old"`
+	New     *Record                `json:"new"`
+	Changes map[Unit]*ChangeRecord `json:"changes,omitempty"`
+}
+
+// WriteJSON writes benchmarks in the sets as JSON records.
+func WriteJSON(w io.Writer, sets []*Set) error {
+	records := make([]*Record, 0)
+	for _, set := range sets {
+		for _, b := range set.Benchmarks {
+			records = append(records, newRecord(set.Package, b))
+		}
+	}
+	return writeJSON(w, records)
+}
+
+// WriteCompareJSON writes comparisons of old and new as JSON records.
+func WriteCompareJSON(w io.Writer, old, new []*Set, opts *Options) error {
+	records := make([]*ComparisonRecord, 0)
+	for _, pair := range PairSets(old, new) {
+		pkg := pair[1].Package
+		for _, c := range Compare(pair[0], pair[1]) {
+			r := &ComparisonRecord{
+				Package: pkg,
+				Name:    c.Name,
+				Procs:   c.Procs,
+				Old:     newRecord(pkg, c.Old),
+				New:     newRecord(pkg, c.New),
+			}
+			if c.Old != nil && c.New != nil {
+				for u, om := range c.Old.Metrics {
+					nm, ok := c.New.Metrics[u]
+					if !ok {
+						continue
+					}
+					if r.Changes == nil {
+						r.Changes = make(map[Unit]*ChangeRecord)
+					}
+					r.Changes[u] = newChangeRecord(u, om, nm, opts)
+				}
+			}
+			records = append(records, r)
+		}
+	}
+	return writeJSON(w, records)
+}
+
+func newChangeRecord(unit Unit, old, new *Metric, opts *Options) *ChangeRecord {
+	r := &ChangeRecord{}
+	if d := delta(old.Value, new.Value); !math.IsInf(d, 0) {
+		r.Delta = &d
+	}
+	if p, ok := significance(old, new); ok {
+		significant := p < opts.Alpha
+		r.P, r.Significant = &p, &significant
+	}
+	if opts.Thresholds != nil {
+		r.Regression = isRegression(unit, old, new, opts.Thresholds, opts.Alpha)
+	}
+	return r
+}
+
+func writeJSON(w io.Writer, v interface{}) error {
+	buf, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "%s\n", buf)
+	return err
+}
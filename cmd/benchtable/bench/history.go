@@ -0,0 +1,233 @@
+package bench
+
+import (
+	"bufio"
+	"encoding/json"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Entry is a run recorded in the history file. The history file is
+// JSON lines, one entry per line, in the order runs are appended.
+type Entry struct {
+	Commit     string    `json:"commit"`
+	Time       time.Time `json:"time"`
+	Benchmarks []*Record `json:"benchmarks"`
+}
+
+// NewEntry returns the entry of the run.
+func NewEntry(sets []*Set, commit string, t time.Time) *Entry {
+	e := &Entry{
+		Commit: commit,
+		Time:   t,
+	}
+	for _, set := range sets {
+		for _, b := range set.Benchmarks {
+			e.Benchmarks = append(e.Benchmarks, newRecord(set.Package, b))
+		}
+	}
+	return e
+}
+
+// label returns the column name of the entry in trend tables.
+func (e *Entry) label() string {
+	if len(e.Commit) > 7 {
+		return e.Commit[:7]
+	}
+	if e.Commit != "" {
+		return e.Commit
+	}
+	return e.Time.Format("2006-01-02 15:04")
+}
+
+// AppendHistory appends the entry to the history file. The file is
+// created when it does not exist.
+func AppendHistory(path string, e *Entry) error {
+	buf, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	if err != nil {
+		return err
+	}
+
+	if _, err := file.Write(append(buf, '\n')); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// ReadHistory reads all entries in the history file.
+func ReadHistory(path string) ([]*Entry, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var entries []*Entry
+	sc := bufio.NewScanner(file)
+	sc.Buffer(make([]byte, 64*1024), 64*1024*1024)
+	for sc.Scan() {
+		l := strings.TrimSpace(sc.Text())
+		if l == "" {
+			continue
+		}
+
+		var e Entry
+		if err := json.Unmarshal([]byte(l), &e); err != nil {
+			return nil, err
+		}
+		entries = append(entries, &e)
+	}
+
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+var sparks = []rune("▁▂▃▄▅▆▇█")
+
+// sparkline draws values scaled between their minimum and maximum.
+// Missing values (NaN) are drawn as spaces.
+func sparkline(values []float64) string {
+	min, max := 0.0, 0.0
+	first := true
+	for _, v := range values {
+		if math.IsNaN(v) {
+			continue
+		}
+		if first || v < min {
+			min = v
+		}
+		if first || v > max {
+			max = v
+		}
+		first = false
+	}
+
+	var b strings.Builder
+	for _, v := range values {
+		switch {
+		case math.IsNaN(v):
+			b.WriteRune(' ')
+		case max == min:
+			b.WriteRune(sparks[len(sparks)/2])
+		default:
+			i := int((v - min) / (max - min) * float64(len(sparks)-1))
+			b.WriteRune(sparks[i])
+		}
+	}
+	return b.String()
+}
+
+// NewTrendTables builds tables, one per package, which show the metric
+// of the given unit of each benchmark across the last n entries with
+// the sparkline and the change from the first to the last value.
+func NewTrendTables(entries []*Entry, unit Unit, n int, opts *Options) []*Table {
+	if n > 0 && len(entries) > n {
+		entries = entries[len(entries)-n:]
+	}
+
+	type row struct {
+		name   string
+		values []float64
+	}
+
+	var (
+		pkgs   []string
+		rows   = make(map[string][]*row)
+		lookup = make(map[[2]string]*row)
+	)
+	for i, e := range entries {
+		for _, b := range e.Benchmarks {
+			v, ok := b.Metrics[unit]
+			if !ok {
+				continue
+			}
+
+			name := b.Name
+			if b.Procs > 1 {
+				name += "-" + strconv.Itoa(b.Procs)
+			}
+
+			key := [2]string{b.Package, name}
+			r, ok := lookup[key]
+			if !ok {
+				r = &row{name: name, values: make([]float64, len(entries))}
+				for j := range r.values {
+					r.values[j] = math.NaN()
+				}
+				if _, ok := rows[b.Package]; !ok {
+					pkgs = append(pkgs, b.Package)
+				}
+				rows[b.Package] = append(rows[b.Package], r)
+				lookup[key] = r
+			}
+			r.values[i] = v
+		}
+	}
+
+	var tables []*Table
+	for _, pkg := range pkgs {
+		var values []float64
+		for _, r := range rows[pkg] {
+			for _, v := range r.values {
+				if !math.IsNaN(v) {
+					values = append(values, v)
+				}
+			}
+		}
+		column := newColumn(unit, values, opts)
+
+		t := &Table{Header: []string{column.header()}}
+		if pkg != "" {
+			t.Caption = "pkg: " + pkg
+		}
+		for _, e := range entries {
+			t.Header = append(t.Header, e.label())
+		}
+		if !opts.Plain {
+			t.Header = append(t.Header, "trend")
+		}
+		t.Header = append(t.Header, "change")
+
+		for _, r := range rows[pkg] {
+			line := []string{r.name}
+			first, last := math.NaN(), math.NaN()
+			for _, v := range r.values {
+				if math.IsNaN(v) {
+					line = append(line, "")
+					continue
+				}
+				if math.IsNaN(first) {
+					first = v
+				}
+				last = v
+				line = append(line, column.value(v))
+			}
+			if !opts.Plain {
+				line = append(line, sparkline(r.values))
+			}
+
+			change := ""
+			if !math.IsNaN(first) {
+				change = formatDelta(delta(first, last))
+			}
+			t.Rows = append(t.Rows, append(line, change))
+		}
+
+		if opts.Plain && pkg != "" {
+			t.prependColumn("pkg", pkg)
+		}
+		tables = append(tables, t)
+	}
+	return tables
+}
@@ -0,0 +1,299 @@
+package bench
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Parse reads go test -bench output from rd. It returns one set per
+// package ("pkg:" line) which has benchmark results. Lines which are
+// neither benchmark results nor header lines (e.g., "ok", "FAIL" and
+// output of t.Log) are ignored. Metrics are summarized by mean.
+//
+// The output can be either plain text or the JSON event stream of
+// go test -json (test2json). The format is detected line by line.
+func Parse(rd io.Reader) ([]*Set, error) {
+	s := newStream()
+	sc := bufio.NewScanner(rd)
+	// Output of t.Log or test2json events can be long lines
+	sc.Buffer(make([]byte, 64*1024), 64*1024*1024)
+	for sc.Scan() {
+		s.parse(sc.Text())
+	}
+
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+
+	sets := s.sets()
+	for _, set := range sets {
+		set.Summarize(Mean)
+	}
+	return sets, nil
+}
+
+// stream parses lines of go test output in either plain text or
+// test2json events.
+type stream struct {
+	text *parser
+
+	// Output of go test -json is split into events at arbitrary
+	// points and events of packages may be interleaved, so it is
+	// buffered per package until the end of line.
+	order []string
+	pkgs  map[string]*parser
+	bufs  map[string]string
+
+	// failed is true when a test or a package failed.
+	failed bool
+}
+
+func newStream() *stream {
+	return &stream{
+		text: &parser{},
+		pkgs: make(map[string]*parser),
+		bufs: make(map[string]string),
+	}
+}
+
+func (s *stream) parse(l string) {
+	ev, ok := parseEvent(l)
+	if !ok {
+		if isFail(l) {
+			s.failed = true
+		}
+		s.text.parse(l)
+		return
+	}
+	if ev.Action == "fail" {
+		s.failed = true
+	}
+	if ev.Action != "output" {
+		return
+	}
+
+	p, ok := s.pkgs[ev.Package]
+	if !ok {
+		p = &parser{}
+		if ev.Package != "" {
+			p.setConfig("pkg", ev.Package)
+		}
+		s.pkgs[ev.Package] = p
+		s.order = append(s.order, ev.Package)
+	}
+
+	buf := s.bufs[ev.Package] + ev.Output
+	for {
+		i := strings.IndexByte(buf, '\n')
+		if i < 0 {
+			break
+		}
+		p.parse(buf[:i])
+		buf = buf[i+1:]
+	}
+	s.bufs[ev.Package] = buf
+}
+
+// results returns the number of result lines parsed so far.
+func (s *stream) results() int {
+	n := s.text.results
+	for _, p := range s.pkgs {
+		n += p.results
+	}
+	return n
+}
+
+// sets flushes buffered output and returns sets parsed so far.
+func (s *stream) sets() []*Set {
+	sets := s.text.sets
+	for _, pkg := range s.order {
+		p := s.pkgs[pkg]
+		if buf := s.bufs[pkg]; buf != "" {
+			p.parse(buf)
+			s.bufs[pkg] = ""
+		}
+		sets = append(sets, p.sets...)
+	}
+	return sets
+}
+
+// isFail reports whether the line reports a failure of go test (e.g.,
+// "--- FAIL: TestX", "FAIL" or "FAIL	pkg	0.1s").
+func isFail(l string) bool {
+	l = strings.TrimSpace(l)
+	return l == "FAIL" || strings.HasPrefix(l, "FAIL\t") || strings.HasPrefix(l, "--- FAIL")
+}
+
+// event is an event of go test -json (see go doc cmd/test2json).
+type event struct {
+	Action  string
+	Package string
+	Test    string
+	Output  string
+}
+
+// parseEvent parses the line as a test2json event.
+func parseEvent(l string) (*event, bool) {
+	if !strings.HasPrefix(l, "{") {
+		return nil, false
+	}
+
+	var ev event
+	if err := json.Unmarshal([]byte(l), &ev); err != nil || ev.Action == "" {
+		return nil, false
+	}
+	return &ev, true
+}
+
+// parser keeps the state of parsing go test output.
+type parser struct {
+	sets []*Set
+
+	// cur is the set which results are added to. It is nil until the
+	// first result after header lines.
+	cur *Set
+
+	// config is the header lines seen so far. Header lines of the next
+	// package (goos, goarch) are printed before its "pkg:" line and
+	// some (cpu) after it.
+	config []*Config
+
+	// name is the benchmark name printed on its own line. Output of the
+	// benchmark can be printed between the name and the result.
+	name string
+
+	// results is the number of result lines parsed.
+	results int
+}
+
+var reConfig = regexp.MustCompile(`^([a-z][a-zA-Z0-9_-]*):\s*(.*)$`)
+
+func (p *parser) parse(l string) {
+	if m := reConfig.FindStringSubmatch(l); m != nil {
+		p.setConfig(m[1], strings.TrimSpace(m[2]))
+		return
+	}
+
+	r, ok := parseLine(l)
+	if !ok && p.name != "" {
+		// Result printed after the output of the benchmark
+		r, ok = parseLine(p.name + " " + l)
+	}
+	if !ok {
+		if fields := strings.Fields(l); len(fields) > 0 && strings.HasPrefix(fields[0], "Benchmark") {
+			p.name = fields[0]
+		}
+		return
+	}
+	p.name = ""
+	p.results++
+
+	if p.cur == nil {
+		p.cur = &Set{}
+		for _, c := range p.config {
+			p.cur.Config = append(p.cur.Config, c)
+			if c.Key == "pkg" {
+				p.cur.Package = c.Value
+			}
+		}
+		p.sets = append(p.sets, p.cur)
+	}
+	p.cur.add(r)
+}
+
+func (p *parser) setConfig(key, value string) {
+	// Only lines which start the header of a package end the results of
+	// the set. Others in the middle of results (e.g., "size: 42" printed
+	// by a benchmark) are not header lines and ignored.
+	if p.cur != nil {
+		if key != "goos" && key != "goarch" && key != "pkg" {
+			return
+		}
+		p.cur = nil
+	}
+
+	if key == "pkg" {
+		// Lines like cpu are printed after pkg, so drop ones of the
+		// previous package.
+		config := make([]*Config, 0, len(p.config))
+		for _, c := range p.config {
+			if c.Key == "goos" || c.Key == "goarch" {
+				config = append(config, c)
+			}
+		}
+		p.config = config
+	}
+
+	for i, c := range p.config {
+		if c.Key == key {
+			p.config[i] = &Config{Key: key, Value: value}
+			return
+		}
+	}
+	p.config = append(p.config, &Config{Key: key, Value: value})
+}
+
+// result is a single benchmark result line.
+type result struct {
+	fullName   string
+	name       string
+	procs      int
+	iterations int
+	units      []Unit
+	values     []float64
+}
+
+var reProcs = regexp.MustCompile(`-(\d+)$`)
+
+// parseLine parses a benchmark result line like,
+//
+//	BenchmarkSort1K/Quick-4   30000   47545 ns/op   0 B/op   0 allocs/op
+//
+// The line consists of name, the number of iterations and pairs of
+// value and unit. The GOMAXPROCS suffix of the name is parsed into procs.
+func parseLine(l string) (*result, bool) {
+	fields := strings.Fields(l)
+	if len(fields) < 2 || len(fields)%2 != 0 {
+		return nil, false
+	}
+
+	if !strings.HasPrefix(fields[0], "Benchmark") {
+		return nil, false
+	}
+
+	n, err := strconv.Atoi(fields[1])
+	if err != nil {
+		return nil, false
+	}
+
+	r := &result{
+		fullName:   fields[0],
+		name:       fields[0],
+		procs:      1,
+		iterations: n,
+	}
+	if m := reProcs.FindStringSubmatch(r.name); m != nil {
+		r.procs, _ = strconv.Atoi(m[1])
+		r.name = r.name[:len(r.name)-len(m[0])]
+	}
+	for i := 2; i < len(fields); i += 2 {
+		v, err := strconv.ParseFloat(fields[i], 64)
+		if err != nil {
+			return nil, false
+		}
+		r.units = append(r.units, Unit(fields[i+1]))
+		r.values = append(r.values, v)
+	}
+
+	return r, true
+}
+
+// formatValue formats a metric value without losing precision
+// (e.g., 47545 or 0.53).
+func formatValue(v float64) string {
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
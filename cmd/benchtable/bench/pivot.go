@@ -0,0 +1,191 @@
+package bench
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Dimension is a part of a benchmark name. A name like
+// "BenchmarkSort/size=1K/Quick-4" has dimensions name=Sort, size=1K,
+// sub2=Quick and procs=4. Parts without "=" are keyed by their
+// position (sub1, sub2, ...).
+type Dimension struct {
+	Key   string
+	Value string
+}
+
+// SplitName splits the benchmark name (without the GOMAXPROCS suffix)
+// into dimensions.
+func SplitName(name string) []Dimension {
+	name = strings.TrimPrefix(name, "Benchmark")
+
+	var dims []Dimension
+	for i, part := range strings.Split(name, "/") {
+		switch {
+		case i == 0:
+			dims = append(dims, Dimension{Key: "name", Value: part})
+		case strings.Contains(part, "="):
+			kv := strings.SplitN(part, "=", 2)
+			dims = append(dims, Dimension{Key: kv[0], Value: kv[1]})
+		default:
+			dims = append(dims, Dimension{Key: fmt.Sprintf("sub%d", i), Value: part})
+		}
+	}
+	return dims
+}
+
+// Dimensions returns the dimensions of the benchmark including procs.
+func (b *Benchmark) Dimensions() []Dimension {
+	return append(SplitName(b.Name), Dimension{Key: "procs", Value: strconv.Itoa(b.Procs)})
+}
+
+// HasDimension reports whether any benchmark in the set has the
+// dimension of the key.
+func (s *Set) HasDimension(key string) bool {
+	for _, b := range s.Benchmarks {
+		for _, d := range b.Dimensions() {
+			if d.Key == key {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// NewPivotTable builds the table which shows the metric of the given
+// unit with the row dimension as rows and the column dimension as
+// columns. When other dimensions vary among benchmarks, their values
+// are prepended to the row label. Benchmarks which do not have the row
+// or the column dimension are skipped.
+func NewPivotTable(set *Set, row, col string, unit Unit, opts *Options) *Table {
+	// Find dimensions which have more than one value
+	names := make([][]Dimension, len(set.Benchmarks))
+	values := make(map[string][]string)
+	for i, b := range set.Benchmarks {
+		names[i] = b.Dimensions()
+		for _, d := range names[i] {
+			values[d.Key] = appendUnique(values[d.Key], d.Value)
+		}
+	}
+
+	var (
+		rows, cols []string
+		cells      = make(map[[2]string]string)
+		column     = newColumn(unit, metricValues(unit, set), opts)
+	)
+	for i, b := range set.Benchmarks {
+		m, ok := b.Metrics[unit]
+		if !ok {
+			continue
+		}
+
+		var (
+			rv, cv     string
+			hasR, hasC bool
+			rest       []string
+		)
+		for _, d := range names[i] {
+			switch {
+			case d.Key == row:
+				rv, hasR = d.Value, true
+			case d.Key == col:
+				cv, hasC = d.Value, true
+			case len(values[d.Key]) > 1:
+				rest = append(rest, d.Value)
+			}
+		}
+		if !hasR || !hasC {
+			continue
+		}
+
+		label := strings.Join(append(rest, rv), "/")
+		rows = appendUnique(rows, label)
+		cols = appendUnique(cols, cv)
+		cells[[2]string{label, cv}] = column.format(m)
+	}
+
+	title, _ := caption(set)
+	t := &Table{
+		Caption: title,
+		Header:  append([]string{column.header()}, cols...),
+	}
+	for _, r := range rows {
+		line := []string{r}
+		for _, c := range cols {
+			line = append(line, cells[[2]string{r, c}])
+		}
+		t.Rows = append(t.Rows, line)
+	}
+
+	if opts.Plain && set.Package != "" {
+		t.prependColumn("pkg", set.Package)
+	}
+	return t
+}
+
+func appendUnique(ss []string, s string) []string {
+	for _, v := range ss {
+		if v == s {
+			return ss
+		}
+	}
+	return append(ss, s)
+}
+
+// NewProcsTable builds the table which shows the metric of the given
+// unit of each benchmark with GOMAXPROCS (e.g., go test -cpu 1,2,4,8)
+// as columns.
+func NewProcsTable(set *Set, unit Unit, opts *Options) *Table {
+	var (
+		names  []string
+		procs  []int
+		cells  = make(map[string]map[int]string)
+		column = newColumn(unit, metricValues(unit, set), opts)
+	)
+	for _, b := range set.Benchmarks {
+		m, ok := b.Metrics[unit]
+		if !ok {
+			continue
+		}
+
+		if _, ok := cells[b.Name]; !ok {
+			names = append(names, b.Name)
+			cells[b.Name] = make(map[int]string)
+		}
+		cells[b.Name][b.Procs] = column.format(m)
+
+		found := false
+		for _, p := range procs {
+			if p == b.Procs {
+				found = true
+				break
+			}
+		}
+		if !found {
+			procs = append(procs, b.Procs)
+		}
+	}
+	sort.Ints(procs)
+
+	t := &Table{
+		Caption: set.Caption(),
+		Header:  []string{column.header()},
+	}
+	for _, p := range procs {
+		t.Header = append(t.Header, "procs="+strconv.Itoa(p))
+	}
+	for _, name := range names {
+		line := []string{name}
+		for _, p := range procs {
+			line = append(line, cells[name][p])
+		}
+		t.Rows = append(t.Rows, line)
+	}
+
+	if opts.Plain && set.Package != "" {
+		t.prependColumn("pkg", set.Package)
+	}
+	return t
+}
@@ -0,0 +1,82 @@
+package bench
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// group returns the sub-benchmark group of the benchmark which is the
+// name up to the last "/" and GOMAXPROCS (e.g., "BenchmarkSort1K-4"
+// of "BenchmarkSort1K/Quick-4"). Benchmarks without sub-benchmarks
+// belong to the same group.
+func group(b *Benchmark) string {
+	parent := ""
+	if i := strings.LastIndex(b.Name, "/"); i >= 0 {
+		parent = b.Name[:i]
+	}
+	return parent + "-" + strconv.Itoa(b.Procs)
+}
+
+// matchReference reports whether the benchmark is the reference given
+// by exact name (with or without the GOMAXPROCS suffix, or its last
+// part like "Std") or by regular expression.
+func matchReference(b *Benchmark, ref string, re *regexp.Regexp, exact bool) bool {
+	if exact {
+		last := b.Name
+		if i := strings.LastIndex(last, "/"); i >= 0 {
+			last = last[i+1:]
+		}
+		return ref == b.Name || ref == b.FullName() || ref == last
+	}
+	return re != nil && re.MatchString(b.Name)
+}
+
+// References returns the reference benchmark of each sub-benchmark
+// group keyed by the group. The reference is given by exact name or
+// regular expression. An exact match is preferred and the first
+// match is used when multiple benchmarks match in a group. For a
+// filtered set, the reference is looked up in the original set, so
+// ratios are available even when the reference itself is filtered out.
+func References(set *Set, ref string) (map[string]*Benchmark, error) {
+	for set.parent != nil {
+		set = set.parent
+	}
+
+	re, err := regexp.Compile(ref)
+	if err != nil {
+		// It can be still used as an exact name
+		re = nil
+	}
+
+	refs := make(map[string]*Benchmark)
+	for _, exact := range []bool{true, false} {
+		for _, b := range set.Benchmarks {
+			g := group(b)
+			if _, ok := refs[g]; ok {
+				continue
+			}
+			if matchReference(b, ref, re, exact) {
+				refs[g] = b
+			}
+		}
+	}
+
+	if len(refs) == 0 && len(set.Benchmarks) > 0 {
+		return nil, fmt.Errorf("no benchmark matches reference %q", ref)
+	}
+	return refs, nil
+}
+
+// formatRatio formats the ratio of the value to the reference value
+// (e.g., 0.39x). It returns empty string when the ratio is undefined.
+func formatRatio(v, ref float64, plain bool) string {
+	if ref == 0 {
+		return ""
+	}
+	if plain {
+		return strconv.FormatFloat(v/ref, 'f', 2, 64)
+	}
+	return strconv.FormatFloat(v/ref, 'f', 2, 64) + "x"
+}
@@ -0,0 +1,60 @@
+package bench
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+)
+
+// Markers of the section which is replaced by ReplaceSection.
+const (
+	StartMarker = "<!-- benchtable:start -->"
+	EndMarker   = "<!-- benchtable:end -->"
+)
+
+// ReplaceSection replaces the content between StartMarker and EndMarker
+// in the document with the given content. Markers are kept so that the
+// section can be replaced again. It returns an error when the markers
+// are missing or in the wrong order.
+func ReplaceSection(doc, content []byte) ([]byte, error) {
+	start := bytes.Index(doc, []byte(StartMarker))
+	end := bytes.Index(doc, []byte(EndMarker))
+	if start < 0 || end < 0 {
+		return nil, fmt.Errorf("markers %s and %s not found", StartMarker, EndMarker)
+	}
+	if end < start {
+		return nil, fmt.Errorf("%s must be placed before %s", StartMarker, EndMarker)
+	}
+	start += len(StartMarker)
+
+	var buf bytes.Buffer
+	buf.Write(doc[:start])
+	buf.WriteString("\n\n")
+	buf.Write(bytes.TrimSpace(content))
+	buf.WriteString("\n\n")
+	buf.Write(doc[end:])
+	return buf.Bytes(), nil
+}
+
+// UpdateSection replaces the section of the file with the content. When
+// check is true, the file is not written and it only reports whether the
+// section is stale. It returns true when the section was (or would be)
+// changed.
+func UpdateSection(path string, content []byte, check bool) (bool, error) {
+	doc, err := ioutil.ReadFile(path)
+	if err != nil {
+		return false, err
+	}
+
+	updated, err := ReplaceSection(doc, content)
+	if err != nil {
+		return false, fmt.Errorf("%s: %s", path, err)
+	}
+	if bytes.Equal(doc, updated) {
+		return false, nil
+	}
+	if check {
+		return true, nil
+	}
+	return true, ioutil.WriteFile(path, updated, 0644)
+}
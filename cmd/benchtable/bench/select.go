@@ -0,0 +1,143 @@
+package bench
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Match returns the set of benchmarks whose full name matches include
+// and does not match exclude. A nil regular expression is ignored.
+func (s *Set) Match(include, exclude *regexp.Regexp) *Set {
+	return s.Filter(func(b *Benchmark) bool {
+		name := b.FullName()
+		if include != nil && !include.MatchString(name) {
+			return false
+		}
+		if exclude != nil && exclude.MatchString(name) {
+			return false
+		}
+		return true
+	})
+}
+
+// Sort sorts benchmarks in the set by the key which is "name" or a
+// unit. Benchmarks which do not have the unit are put last in both
+// orders.
+func (s *Set) Sort(key string, desc bool) {
+	if key == "name" {
+		sort.SliceStable(s.Benchmarks, func(i, j int) bool {
+			if desc {
+				return s.Benchmarks[i].FullName() > s.Benchmarks[j].FullName()
+			}
+			return s.Benchmarks[i].FullName() < s.Benchmarks[j].FullName()
+		})
+		return
+	}
+
+	unit := Unit(key)
+	sort.SliceStable(s.Benchmarks, func(i, j int) bool {
+		mi, oki := s.Benchmarks[i].Metrics[unit]
+		mj, okj := s.Benchmarks[j].Metrics[unit]
+		if !oki || !okj {
+			return oki && !okj
+		}
+		if desc {
+			return mi.Value > mj.Value
+		}
+		return mi.Value < mj.Value
+	})
+}
+
+// ParseSortKey parses the sort key like "ns/op" (ascending) or
+// "-ns/op" (descending).
+func ParseSortKey(s string) (string, bool) {
+	if strings.HasPrefix(s, "-") {
+		return s[1:], true
+	}
+	return strings.TrimPrefix(s, "+"), false
+}
+
+// Head returns the set of the first n benchmarks.
+func (s *Set) Head(n int) *Set {
+	i := 0
+	return s.Filter(func(b *Benchmark) bool {
+		i++
+		return i <= n
+	})
+}
+
+// key returns the key of the column which is used to select columns.
+// It is the header name unless the table builder sets it (e.g., the
+// unit for "old ns/op" of compare tables).
+func (t *Table) key(col int) string {
+	if col < len(t.keys) && t.keys[col] != "" {
+		return t.keys[col]
+	}
+	return t.Header[col]
+}
+
+// HasColumn reports whether the table has the column of the key.
+func (t *Table) HasColumn(key string) bool {
+	for i := range t.Header {
+		if t.key(i) == key {
+			return true
+		}
+	}
+	return false
+}
+
+// Select keeps only the columns of the given keys in that order. The
+// key is "name", "times", "procs" or a unit like "ns/op". For a unit,
+// all columns derived from it (e.g., its spread, ratio or old, new and
+// delta of compare tables) are kept. Unknown keys are ignored. The pkg
+// column of plain tables is always kept.
+func (t *Table) Select(keys []string) {
+	var idx []int
+	if t.HasColumn("pkg") && !containsString(keys, "pkg") {
+		keys = append([]string{"pkg"}, keys...)
+	}
+	for _, k := range keys {
+		for i := range t.Header {
+			if t.key(i) == k {
+				idx = append(idx, i)
+			}
+		}
+	}
+
+	header := make([]string, len(idx))
+	colKeys := make([]string, len(idx))
+	for j, i := range idx {
+		header[j] = t.Header[i]
+		colKeys[j] = t.key(i)
+	}
+
+	rows := make([][]string, len(t.Rows))
+	for r, row := range t.Rows {
+		rows[r] = make([]string, len(idx))
+		for j, i := range idx {
+			rows[r][j] = row[i]
+		}
+	}
+
+	marks := t.marks
+	t.marks = nil
+	for k := range marks {
+		for j, i := range idx {
+			if i == k[1] {
+				t.Mark(k[0], j)
+			}
+		}
+	}
+
+	t.Header, t.keys, t.Rows = header, colKeys, rows
+}
+
+func containsString(ss []string, s string) bool {
+	for _, v := range ss {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
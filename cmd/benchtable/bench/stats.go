@@ -0,0 +1,252 @@
+package bench
+
+import (
+	"fmt"
+	"math"
+	"sort"
+)
+
+// Stat is the statistic used to summarize samples of a metric.
+type Stat int
+
+const (
+	Mean Stat = iota
+	Median
+)
+
+// ParseStat parses the name of the statistic ("mean" or "median").
+func ParseStat(s string) (Stat, error) {
+	switch s {
+	case "mean":
+		return Mean, nil
+	case "median":
+		return Median, nil
+	}
+	return 0, fmt.Errorf("unknown stat %q (must be mean or median)", s)
+}
+
+// Summarize sets Value, Spread and Outliers of all metrics in the set.
+// Outliers are detected by Tukey's fences (1.5 times the interquartile
+// range below the first or above the third quartile).
+func (s *Set) Summarize(stat Stat) {
+	for _, b := range s.Benchmarks {
+		for _, m := range b.Metrics {
+			m.summarize(stat)
+		}
+	}
+}
+
+func (m *Metric) summarize(stat Stat) {
+	samples := removeOutliers(m.Samples)
+	m.Outliers = len(m.Samples) - len(samples)
+
+	switch stat {
+	case Median:
+		m.Value = median(samples)
+	default:
+		m.Value = mean(samples)
+	}
+
+	m.Spread = 0
+	if m.Value == 0 {
+		return
+	}
+	for _, v := range samples {
+		d := math.Abs(v-m.Value) / math.Abs(m.Value) * 100
+		if d > m.Spread {
+			m.Spread = d
+		}
+	}
+}
+
+// removeOutliers returns samples within Tukey's fences. The order of
+// samples is kept.
+func removeOutliers(samples []float64) []float64 {
+	if len(samples) < 4 {
+		return samples
+	}
+
+	sorted := make([]float64, len(samples))
+	copy(sorted, samples)
+	sort.Float64s(sorted)
+
+	q1, q3 := quantile(sorted, 0.25), quantile(sorted, 0.75)
+	iqr := q3 - q1
+	lo, hi := q1-1.5*iqr, q3+1.5*iqr
+
+	kept := make([]float64, 0, len(samples))
+	for _, v := range samples {
+		if v < lo || v > hi {
+			continue
+		}
+		kept = append(kept, v)
+	}
+	return kept
+}
+
+// quantile returns the q-quantile of sorted samples by linear
+// interpolation.
+func quantile(sorted []float64, q float64) float64 {
+	if len(sorted) == 0 {
+		return 0
+	}
+	pos := q * float64(len(sorted)-1)
+	i := int(pos)
+	if i+1 >= len(sorted) {
+		return sorted[len(sorted)-1]
+	}
+	frac := pos - float64(i)
+	return sorted[i] + frac*(sorted[i+1]-sorted[i])
+}
+
+func mean(samples []float64) float64 {
+	if len(samples) == 0 {
+		return 0
+	}
+	var sum float64
+	for _, v := range samples {
+		sum += v
+	}
+	return sum / float64(len(samples))
+}
+
+func median(samples []float64) float64 {
+	sorted := make([]float64, len(samples))
+	copy(sorted, samples)
+	sort.Float64s(sorted)
+	return quantile(sorted, 0.5)
+}
+
+// round rounds v to 2 decimal places, or to 3 significant digits
+// when its absolute value is less than 1.
+func round(v float64) float64 {
+	if v == 0 || math.IsInf(v, 0) || math.IsNaN(v) {
+		return v
+	}
+	if math.Abs(v) >= 1 {
+		return math.Round(v*100) / 100
+	}
+	p := math.Pow(10, 2-math.Floor(math.Log10(math.Abs(v))))
+	return math.Round(v*p) / p
+}
+
+// MinPValue returns the smallest two-sided p-value the Mann-Whitney U
+// test can produce with samples of sizes n1 and n2, which is when the
+// samples do not overlap (e.g., 0.100 for 3 and 3).
+func MinPValue(n1, n2 int) float64 {
+	// 2 / C(n1+n2, n1)
+	c := 1.0
+	for i := 1; i <= n1; i++ {
+		c = c * float64(n2+i) / float64(i)
+	}
+	return math.Min(1, 2/c)
+}
+
+// MannWhitneyU returns the two-sided p-value of the Mann-Whitney U test
+// which tests whether samples x and y come from the same distribution.
+// It uses the exact distribution of U for small samples without ties,
+// otherwise the normal approximation with tie correction.
+func MannWhitneyU(x, y []float64) float64 {
+	n1, n2 := len(x), len(y)
+	if n1 == 0 || n2 == 0 {
+		return 1
+	}
+
+	type obs struct {
+		v     float64
+		group int
+	}
+	all := make([]obs, 0, n1+n2)
+	for _, v := range x {
+		all = append(all, obs{v, 0})
+	}
+	for _, v := range y {
+		all = append(all, obs{v, 1})
+	}
+	sort.Slice(all, func(i, j int) bool { return all[i].v < all[j].v })
+
+	// Assign ranks with averaging ranks of ties.
+	var (
+		r1      float64
+		tieTerm float64
+		ties    bool
+	)
+	for i := 0; i < len(all); {
+		j := i
+		for j < len(all) && all[j].v == all[i].v {
+			j++
+		}
+		rank := float64(i+j+1) / 2
+		for k := i; k < j; k++ {
+			if all[k].group == 0 {
+				r1 += rank
+			}
+		}
+		if t := float64(j - i); t > 1 {
+			ties = true
+			tieTerm += t*t*t - t
+		}
+		i = j
+	}
+
+	u1 := r1 - float64(n1*(n1+1))/2
+	u := math.Min(u1, float64(n1*n2)-u1)
+
+	if !ties && n1 <= 50 && n2 <= 50 {
+		return exactUPValue(n1, n2, u)
+	}
+
+	n := float64(n1 + n2)
+	mu := float64(n1*n2) / 2
+	sigma := math.Sqrt(float64(n1*n2) / 12 * ((n + 1) - tieTerm/(n*(n-1))))
+	if sigma == 0 {
+		return 1
+	}
+	z := (math.Abs(u-mu) - 0.5) / sigma
+	if z < 0 {
+		z = 0
+	}
+	return math.Min(1, math.Erfc(z/math.Sqrt2))
+}
+
+// exactUPValue returns the two-sided p-value of U (the smaller of U1 and
+// U2) by counting arrangements of two groups of size n1 and n2 whose
+// statistic is less than or equal to u.
+func exactUPValue(n1, n2 int, u float64) float64 {
+	max := n1 * n2
+
+	// counts[i][j][k] is the number of arrangements of i and j elements
+	// whose U is k. It is computed by the recurrence
+	// f(i, j, k) = f(i-1, j, k-j) + f(i, j-1, k).
+	prev := make([][]float64, n2+1)
+	for j := range prev {
+		prev[j] = make([]float64, max+1)
+		prev[j][0] = 1
+	}
+	for i := 1; i <= n1; i++ {
+		cur := make([][]float64, n2+1)
+		cur[0] = make([]float64, max+1)
+		cur[0][0] = 1
+		for j := 1; j <= n2; j++ {
+			cur[j] = make([]float64, max+1)
+			for k := 0; k <= i*j; k++ {
+				c := cur[j-1][k]
+				if k >= j {
+					c += prev[j][k-j]
+				}
+				cur[j][k] = c
+			}
+		}
+		prev = cur
+	}
+
+	counts := prev[n2]
+	var total, tail float64
+	for k, c := range counts {
+		total += c
+		if float64(k) <= u {
+			tail += c
+		}
+	}
+	return math.Min(1, 2*tail/total)
+}
@@ -0,0 +1,219 @@
+package bench
+
+import (
+	"strconv"
+)
+
+// Table is a table built from benchmark results. It is independent
+// from the output format and written by one of the format writers.
+type Table struct {
+	// Caption describes the environment of the results (e.g., goos,
+	// goarch, pkg and cpu).
+	Caption string
+
+	Header []string
+	Rows   [][]string
+
+	// keys are keys of columns used by Select. See Table.key.
+	keys []string
+
+	// marks is the set of highlighted cells (e.g., regressions) keyed
+	// by row and column index.
+	marks map[[2]int]bool
+}
+
+// Options configures how tables are built.
+type Options struct {
+	// Plain makes cells contain only numbers (e.g., for CSV). The
+	// spread of repeated runs and the p-value are put in their own
+	// columns and the package is put in the first column instead of
+	// the caption.
+	Plain bool
+
+	// Alpha is the significance level of the Mann-Whitney U test in
+	// compare tables.
+	Alpha float64
+
+	// Human rescales time and byte metrics per column (e.g., µs/op or
+	// KiB/op) and formats numbers with thousands separators and fixed
+	// significant digits. It is ignored for plain tables.
+	Human bool
+
+	// Reference is the name or the regular expression of the benchmark
+	// which other benchmarks in the same sub-benchmark group are
+	// compared to. A ratio column is added for each metric when it is
+	// not empty.
+	Reference string
+
+	// Thresholds highlights changes in compare tables which regress
+	// more than the threshold. Nothing is highlighted when it is nil.
+	Thresholds Thresholds
+}
+
+// Mark highlights the cell.
+func (t *Table) Mark(row, col int) {
+	if t.marks == nil {
+		t.marks = make(map[[2]int]bool)
+	}
+	t.marks[[2]int{row, col}] = true
+}
+
+// Marked reports whether the cell is highlighted.
+func (t *Table) Marked(row, col int) bool {
+	return t.marks[[2]int{row, col}]
+}
+
+// addColumns adds columns of the key to the header.
+func (t *Table) addColumns(key string, names ...string) {
+	for len(t.keys) < len(t.Header) {
+		t.keys = append(t.keys, "")
+	}
+	for _, name := range names {
+		t.Header = append(t.Header, name)
+		t.keys = append(t.keys, key)
+	}
+}
+
+// prependColumn adds a column which has the same value in all rows
+// at the beginning of the table.
+func (t *Table) prependColumn(name, value string) {
+	t.Header = append([]string{name}, t.Header...)
+	if t.keys != nil {
+		t.keys = append([]string{name}, t.keys...)
+	}
+	for i, row := range t.Rows {
+		t.Rows[i] = append([]string{value}, row...)
+	}
+
+	marks := t.marks
+	t.marks = nil
+	for k := range marks {
+		t.Mark(k[0], k[1]+1)
+	}
+}
+
+// caption returns the caption of the table of the sets. The caption
+// is taken from the first set. When all benchmarks in the sets run with
+// the same GOMAXPROCS, it is appended to the caption. Otherwise it
+// returns true and the table should have the procs column.
+func caption(sets ...*Set) (string, bool) {
+	var procs []int
+	for _, s := range sets {
+		for _, b := range s.Benchmarks {
+			found := false
+			for _, p := range procs {
+				if p == b.Procs {
+					found = true
+					break
+				}
+			}
+			if !found {
+				procs = append(procs, b.Procs)
+			}
+		}
+	}
+
+	c := sets[0].Caption()
+	if len(procs) > 1 {
+		return c, true
+	}
+
+	if len(procs) == 1 && procs[0] > 1 {
+		if c != "" {
+			c += ", "
+		}
+		c += "procs: " + strconv.Itoa(procs[0])
+	}
+	return c, false
+}
+
+// hasSamples reports whether any benchmark in the set is run
+// multiple times.
+func hasSamples(set *Set) bool {
+	for _, b := range set.Benchmarks {
+		if b.Runs > 1 {
+			return true
+		}
+	}
+	return false
+}
+
+// NewTable builds the table of the given set. The header is built from
+// all units in the set and a cell is left blank when the benchmark did
+// not report the unit. The times column shows iterations per run like
+// the metrics and the runs column is added when benchmarks are run
+// multiple times.
+func NewTable(set *Set, opts *Options) *Table {
+	plain := opts.Plain
+	spread := plain && hasSamples(set)
+
+	var refs map[string]*Benchmark
+	if opts.Reference != "" {
+		// Groups without the reference have blank ratios
+		refs, _ = References(set, opts.Reference)
+	}
+
+	title, procsColumn := caption(set)
+	t := &Table{Caption: title}
+	t.addColumns("name", "name")
+	if procsColumn {
+		t.addColumns("procs", "procs")
+	}
+	t.addColumns("times", "times")
+	runs := hasSamples(set)
+	if runs {
+		t.addColumns("runs", "runs")
+	}
+	cols := make([]*column, len(set.Units))
+	for i, u := range set.Units {
+		cols[i] = newColumn(u, metricValues(u, set), opts)
+		t.addColumns(string(u), cols[i].header())
+		if spread {
+			t.addColumns(string(u), string(u)+" ±%")
+		}
+		if refs != nil {
+			t.addColumns(string(u), string(u)+" ratio")
+		}
+	}
+
+	for _, b := range set.Benchmarks {
+		row := []string{b.Name}
+		if procsColumn {
+			row = append(row, strconv.Itoa(b.Procs))
+		}
+		row = append(row, strconv.Itoa(b.IterationsPerRun()))
+		if runs {
+			row = append(row, strconv.Itoa(b.Runs))
+		}
+		for i, u := range set.Units {
+			m, ok := b.Metrics[u]
+			switch {
+			case !ok:
+				row = append(row, "")
+				if spread {
+					row = append(row, "")
+				}
+			case spread:
+				row = append(row, cols[i].format(m), formatValue(round(m.Spread)))
+			default:
+				row = append(row, cols[i].format(m))
+			}
+
+			if refs != nil {
+				ratio := ""
+				if ref, ok := refs[group(b)]; ok && m != nil {
+					if rm, ok := ref.Metrics[u]; ok {
+						ratio = formatRatio(m.Value, rm.Value, plain)
+					}
+				}
+				row = append(row, ratio)
+			}
+		}
+		t.Rows = append(t.Rows, row)
+	}
+
+	if plain && set.Package != "" {
+		t.prependColumn("pkg", set.Package)
+	}
+	return t
+}
@@ -0,0 +1,68 @@
+package bench
+
+import (
+	"bytes"
+	"io"
+)
+
+// Tee is a reader which copies go test output read from R to W as it
+// arrives (e.g., to keep the raw output while building tables). It
+// keeps track of finished benchmarks and failures in the output.
+type Tee struct {
+	R io.Reader
+	W io.Writer
+
+	// Progress is called with the number of finished benchmarks when a
+	// benchmark finishes. It can be nil.
+	Progress func(n int)
+
+	s    *stream
+	line []byte
+}
+
+// Read reads from R and writes what is read to W.
+func (t *Tee) Read(p []byte) (int, error) {
+	if t.s == nil {
+		t.s = newStream()
+	}
+
+	n, err := t.R.Read(p)
+	if n > 0 {
+		if _, err := t.W.Write(p[:n]); err != nil {
+			return n, err
+		}
+		t.scan(p[:n])
+	}
+	if err == io.EOF && len(t.line) > 0 {
+		t.parse(string(t.line))
+		t.line = nil
+	}
+	return n, err
+}
+
+// scan parses complete lines in the output.
+func (t *Tee) scan(b []byte) {
+	t.line = append(t.line, b...)
+	for {
+		i := bytes.IndexByte(t.line, '\n')
+		if i < 0 {
+			return
+		}
+		t.parse(string(bytes.TrimSuffix(t.line[:i], []byte("\r"))))
+		t.line = t.line[i+1:]
+	}
+}
+
+func (t *Tee) parse(l string) {
+	n := t.s.results()
+	t.s.parse(l)
+	if t.Progress != nil && t.s.results() > n {
+		t.Progress(t.s.results())
+	}
+}
+
+// Failed reports whether a test or a package failed in the output read
+// so far (e.g., "FAIL" lines).
+func (t *Tee) Failed() bool {
+	return t.s != nil && t.s.failed
+}
@@ -0,0 +1,113 @@
+package bench
+
+import (
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"text/template"
+)
+
+// TemplateData is the data which user-defined templates are executed
+// with. Sets is set for results and Pairs is set in compare mode.
+type TemplateData struct {
+	Sets  []*Set
+	Pairs []*SetPair
+}
+
+// SetPair is the pair of old and new sets of a package in compare
+// mode. Units is the union of units of both sets.
+type SetPair struct {
+	Package     string
+	Caption     string
+	Units       []Unit
+	Old         *Set
+	New         *Set
+	Comparisons []*Comparison
+}
+
+// templateFuncs returns helper functions of templates. They accept nil
+// metrics (e.g., benchmarks on only one side) and return the zero value.
+//
+//	metric BENCHMARK UNIT  the metric of the unit or nil
+//	format METRIC          the value with its unit (e.g., 47.5 µs/op with -human)
+//	delta OLD NEW          the percentage change (e.g., +12.30%)
+//	significant OLD NEW    whether the change is significant (see Options.Alpha)
+//	regressed OLD NEW      whether the change exceeds the threshold of the unit
+//	highlight TEXT         the text in bold markdown
+//	caption SET            the environment of the set (goos, goarch, pkg, cpu)
+func templateFuncs(opts *Options) template.FuncMap {
+	return template.FuncMap{
+		"metric": func(b *Benchmark, unit Unit) *Metric {
+			if b == nil {
+				return nil
+			}
+			return b.Metrics[unit]
+		},
+		"format": func(m *Metric) string {
+			if m == nil {
+				return ""
+			}
+			c := newColumn(m.Unit, []float64{m.Value}, opts)
+			return c.format(m) + " " + c.header()
+		},
+		"delta": func(old, new *Metric) string {
+			if old == nil || new == nil {
+				return ""
+			}
+			return formatDelta(delta(old.Value, new.Value))
+		},
+		"significant": func(old, new *Metric) bool {
+			if old == nil || new == nil {
+				return false
+			}
+			p, ok := significance(old, new)
+			return !ok || p < opts.Alpha
+		},
+		"regressed": func(old, new *Metric) bool {
+			if old == nil || new == nil {
+				return false
+			}
+			return isRegression(new.Unit, old, new, opts.Thresholds, opts.Alpha)
+		},
+		"highlight": func(s string) string {
+			return "**" + s + "**"
+		},
+		"caption": func(s *Set) string {
+			c, _ := caption(s)
+			return c
+		},
+	}
+}
+
+// ParseTemplateFile parses the text/template file with helper functions
+// (see templateFuncs).
+func ParseTemplateFile(path string, opts *Options) (*template.Template, error) {
+	text, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return template.New(filepath.Base(path)).Funcs(templateFuncs(opts)).Parse(string(text))
+}
+
+// ExecuteTemplate executes the template with the sets.
+func ExecuteTemplate(w io.Writer, tmpl *template.Template, sets []*Set) error {
+	return tmpl.Execute(w, &TemplateData{Sets: sets})
+}
+
+// ExecuteCompareTemplate executes the template with pairs of old and new
+// sets.
+func ExecuteCompareTemplate(w io.Writer, tmpl *template.Template, old, new []*Set) error {
+	data := &TemplateData{}
+	for _, pair := range PairSets(old, new) {
+		title, _ := caption(pair[1], pair[0])
+		data.Pairs = append(data.Pairs, &SetPair{
+			Package:     pair[1].Package,
+			Caption:     title,
+			Units:       mergeUnits(pair[0], pair[1]),
+			Old:         pair[0],
+			New:         pair[1],
+			Comparisons: Compare(pair[0], pair[1]),
+		})
+	}
+	return tmpl.Execute(w, data)
+}
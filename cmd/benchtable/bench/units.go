@@ -0,0 +1,216 @@
+package bench
+
+import (
+	"math"
+	"strconv"
+	"strings"
+)
+
+// OpsPerSec is the unit of the metric derived from ns/op by
+// Set.AddOpsPerSec.
+const OpsPerSec Unit = "ops/s"
+
+// AddOpsPerSec adds the ops/s metric derived from ns/op to each
+// benchmark. It must be called before Summarize.
+func (s *Set) AddOpsPerSec() {
+	added := false
+	for _, b := range s.Benchmarks {
+		m, ok := b.Metrics["ns/op"]
+		if !ok {
+			continue
+		}
+
+		ops := &Metric{Unit: OpsPerSec}
+		for _, v := range m.Samples {
+			if v == 0 {
+				continue
+			}
+			ops.Samples = append(ops.Samples, 1e9/v)
+		}
+		if len(ops.Samples) == 0 {
+			continue
+		}
+		b.Metrics[OpsPerSec] = ops
+		added = true
+	}
+
+	if !added {
+		return
+	}
+
+	// Put ops/s right after ns/op
+	var units []Unit
+	for _, u := range s.Units {
+		if u == OpsPerSec {
+			continue
+		}
+		units = append(units, u)
+		if u == "ns/op" {
+			units = append(units, OpsPerSec)
+		}
+	}
+	s.Units = units
+}
+
+// scales are the units a time or a byte quantity is rescaled to.
+var (
+	timeScales = []struct {
+		name   string
+		factor float64
+	}{{"ns", 1}, {"µs", 1e3}, {"ms", 1e6}, {"s", 1e9}}
+
+	byteScales = []struct {
+		name   string
+		factor float64
+	}{{"B", 1}, {"KiB", 1 << 10}, {"MiB", 1 << 20}, {"GiB", 1 << 30}}
+)
+
+// column formats values of a unit in a table. With Options.Human,
+// time (ns) and byte (B) quantities are rescaled per column so that
+// the smallest non-zero value is at least 1, and numbers have
+// thousands separators and fixed significant digits.
+type column struct {
+	unit   Unit
+	name   string
+	factor float64
+	human  bool
+	plain  bool
+}
+
+// newColumn returns the column of the unit. values are all values
+// shown in the column and used to choose the scale.
+func newColumn(unit Unit, values []float64, opts *Options) *column {
+	c := &column{
+		unit:   unit,
+		name:   string(unit),
+		factor: 1,
+		human:  opts.Human && !opts.Plain,
+		plain:  opts.Plain,
+	}
+	if !c.human {
+		return c
+	}
+
+	// Split the quantity like "ns" of "ns/op" or "p99-ns"
+	quantity, rest := string(unit), ""
+	if i := strings.Index(quantity, "/"); i >= 0 {
+		quantity, rest = quantity[:i], quantity[i:]
+	}
+	prefix := ""
+	if i := strings.LastIndex(quantity, "-"); i >= 0 {
+		prefix, quantity = quantity[:i+1], quantity[i+1:]
+	}
+
+	scales := timeScales
+	switch quantity {
+	case "ns":
+	case "B":
+		scales = byteScales
+	default:
+		return c
+	}
+
+	min := math.Inf(1)
+	for _, v := range values {
+		if v != 0 && math.Abs(v) < min {
+			min = math.Abs(v)
+		}
+	}
+	if math.IsInf(min, 1) {
+		// All values are zero (e.g., 0 B/op), keep the base unit
+		return c
+	}
+
+	for _, s := range scales {
+		if min/s.factor < 1 {
+			break
+		}
+		c.name, c.factor = prefix+s.name+rest, s.factor
+	}
+	return c
+}
+
+// header returns the column name with the scaled unit (e.g., µs/op).
+func (c *column) header() string {
+	return c.name
+}
+
+// format formats the metric as a table cell. A plain cell contains only
+// the value. Otherwise the spread of repeated runs is appended.
+func (c *column) format(m *Metric) string {
+	if c.plain {
+		if len(m.Samples) > 1 {
+			return formatValue(round(m.Value))
+		}
+		return formatValue(m.Value)
+	}
+
+	s := c.value(m.Value)
+	if len(m.Samples) < 2 {
+		if !c.human {
+			// Keep the raw value as printed by go test
+			return formatValue(m.Value)
+		}
+		return s
+	}
+	return s + " ± " + strconv.Itoa(int(m.Spread+0.5)) + "%"
+}
+
+// value formats the value in the scale of the column.
+func (c *column) value(v float64) string {
+	if !c.human {
+		return formatValue(round(v))
+	}
+	return formatHuman(v / c.factor)
+}
+
+// humanDigits is the number of significant digits of human-friendly
+// numbers. Integer digits are never dropped.
+const humanDigits = 3
+
+// formatHuman formats v with thousands separators and fixed
+// significant digits (e.g., 1,247 or 47.5 or 0.530). Integers are
+// formatted without fraction.
+func formatHuman(v float64) string {
+	if v == 0 || math.IsInf(v, 0) || math.IsNaN(v) {
+		return formatValue(v)
+	}
+
+	decimals := humanDigits - 1 - int(math.Floor(math.Log10(math.Abs(v))))
+	if decimals < 0 || v == math.Trunc(v) {
+		// Counts like allocs/op have no fraction
+		decimals = 0
+	}
+	s := strconv.FormatFloat(v, 'f', decimals, 64)
+
+	sign := ""
+	if s[0] == '-' {
+		sign, s = "-", s[1:]
+	}
+	frac := ""
+	if i := strings.IndexByte(s, '.'); i >= 0 {
+		s, frac = s[:i], s[i:]
+	}
+
+	var b strings.Builder
+	for i, r := range s {
+		if i > 0 && (len(s)-i)%3 == 0 {
+			b.WriteByte(',')
+		}
+		b.WriteRune(r)
+	}
+	return sign + b.String() + frac
+}
+
+// metricValues returns values of the unit of all benchmarks in sets.
+func metricValues(unit Unit, sets ...*Set) []float64 {
+	var values []float64
+	for _, s := range sets {
+		for _, b := range s.Benchmarks {
+			if m, ok := b.Metrics[unit]; ok {
+				values = append(values, m.Value)
+			}
+		}
+	}
+	return values
+}
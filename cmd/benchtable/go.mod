@@ -0,0 +1,3 @@
+module github.com/tcnksm/misc/cmd/benchtable
+
+go 1.14
@@ -2,36 +2,348 @@
 Command 'benchtable' generates a markdown table from go bench results.
 You can provide benchmark result via stdin or a file.
 
-  $ go test -bench . -benchmem | benchtable
+	$ go test -bench . -benchmem | benchtable
+
+Sub-benchmark names like BenchmarkSort1K/Quick-4 are split into
+dimensions: "name" (Sort1K), "sub1", "sub2", ... (Quick) for each
+part, the key for parts like size=1K and "procs" (4) for the GOMAXPROCS
+suffix. With -pivot, one dimension becomes rows and another becomes
+columns and each cell shows the metric given by -metric.
+
+	$ benchtable -pivot sub1,name -metric ns/op bench.txt
+
+The output of go test -json (e.g., go test -json -bench .) is also
+accepted. The input format is detected automatically.
+
+To paste tables in PR descriptions, use -human. It rescales time and
+byte metrics per column (e.g., µs/op or KiB/op), formats numbers with
+thousands separators and fixed significant digits and adds the ops/s
+column derived from ns/op. The raw output is the default.
+
+To compare implementations, use -ref with the name (e.g., Std) or the
+regular expression of the reference benchmark. A ratio column against
+the reference in the same sub-benchmark group is added for each metric.
+
+	$ benchtable -ref Std bench.txt
+
+To post short tables, benchmarks can be filtered by regexp (-include
+and -exclude), sorted by name or a metric (-sort, prefix with - for
+descending order) and limited to the first N (-top). -columns chooses
+and reorders columns.
+
+	$ benchtable -exclude Bubble -sort -allocs/op -top 3 -columns name,allocs/op,ns/op bench.txt
+
+The GOMAXPROCS suffix of names (e.g., -4) is shown in the caption when
+all benchmarks run with the same value, otherwise in the procs column.
+With -procs, the metric (-metric) of each benchmark is shown per
+GOMAXPROCS side by side to see its scalability.
+
+	$ go test -bench . -cpu 1,2,4,8 | benchtable -procs
+
+The output of multiple packages (e.g., go test -bench ./...) is rendered
+as one table per package with its environment (goos, goarch, pkg and
+cpu) as the caption.
+
+To see results at a glance, use -chart. It draws the metric (-metric)
+of each benchmark as horizontal bars, either with Unicode block
+characters for the terminal (text) or as a self-contained SVG image
+(svg). Bars are grouped by sub-benchmark group and, with -compare, old
+and new are drawn side by side.
+
+	$ go test -bench . | benchtable -chart text
+	$ benchtable -compare -chart svg old.txt new.txt > bench.svg
+
+For a custom layout, use -template with a text/template file. It is
+executed with .Sets (or .Pairs with -compare, each with .Comparisons of
+.Old and .New benchmarks) and helper functions: metric, format, delta,
+significant, regressed, highlight and caption. See
+https://pkg.go.dev/github.com/tcnksm/misc/cmd/benchtable/bench for the
+model.
+
+	$ benchtable -compare -template layout.tmpl old.txt new.txt
+
+where layout.tmpl is like,
+
+	{{range .Pairs}}{{.Caption}}
+	{{range .Comparisons}}{{$d := delta (metric .Old "ns/op") (metric .New "ns/op")}}
+	- {{.Name}}: {{format (metric .New "ns/op")}} {{if regressed (metric .Old "ns/op") (metric .New "ns/op")}}:warning: {{highlight $d}}{{else}}{{$d}}{{end}}
+	{{- end}}
+	{{end}}
+
+To keep the raw output while running benchmarks, use -tee. The input is
+passed through to stderr as it arrives with the number of finished
+benchmarks and the table is printed when the input ends. It exits
+non-zero when go test fails (FAIL lines in the output).
+
+	$ go test -bench . -benchmem 2>&1 | benchtable -tee
+
+To keep tables in a README up to date, use -readme. The content between
+<!-- benchtable:start --> and <!-- benchtable:end --> in the file is
+replaced with the output instead of printing it. With -stale, the file
+is not written and it exits non-zero when the section is out of date
+(e.g., in CI).
+
+	$ go test -bench . -benchmem | benchtable -human -readme README.md
+	$ go test -bench . -benchmem | benchtable -human -readme README.md -stale
+
+Other output formats (csv, tsv, json or html) are available via -format.
+
+	$ go test -bench . -benchmem | benchtable -format csv > bench.csv
+
+To compare two results (e.g., base branch and PR branch), use -compare.
+It shows old value, new value and percentage change of each metric.
+
+	$ benchtable -compare old.txt new.txt
+
+To fail CI on performance regressions, use -check. It prints the same
+table as -compare with regressed cells highlighted and exits non-zero
+when a metric gets worse than its threshold in percent (-threshold).
+For units like MB/s, a smaller value is worse.
+
+	$ benchtable -check -threshold ns/op=5,allocs/op=0 base.txt current.txt
+
+To keep the history of runs, use -history. Each run is appended to the
+file as a JSON line with the git commit and the time. With -trend, the
+metric (-metric) of each benchmark across the last N runs is shown with
+a sparkline instead of reading results.
+
+	$ go test -bench . | benchtable -history bench.jsonl
+	$ benchtable -history bench.jsonl -trend 10
+
+When benchmarks are run multiple times (e.g., with -count=10), results
+of the same benchmark are grouped into one row showing the mean (or the
+median with -stat=median) and the variation. The times column shows
+iterations per run and the runs column shows the number of runs.
+Outliers are removed before summarizing. In compare mode, the
+Mann-Whitney U test is applied and a change which is not significant
+(see -alpha) is shown as "~". When there are too few runs for the test
+to be significant at -alpha (e.g., -count=3 at 0.05), -check compares
+changes with thresholds alone.
+
+The columns are built from all units reported in the results (e.g.,
+ns/op, MB/s or custom units reported by b.ReportMetric). A cell is left
+blank when the benchmark did not report the unit.
 
 See example output on https://gist.github.com/tcnksm/207e60f2e39c2f9b29d6082b1ea020e7
 
-To install it,
+The parser and the renderer are available as a library,
+github.com/tcnksm/misc/cmd/benchtable/bench.
 
-  $ go get github.com/tcnksm/misc/cmd/benchtable
+To install it,
 
+	$ go get github.com/tcnksm/misc/cmd/benchtable
 */
 package main
 
 import (
-	"bufio"
 	"bytes"
-	"fmt"
+	"flag"
 	"io"
+	"io/ioutil"
 	"log"
 	"os"
+	"os/exec"
 	"regexp"
 	"strings"
+	"text/template"
+	"time"
+
+	"github.com/tcnksm/misc/cmd/benchtable/bench"
 )
 
-var items = []string{"name", "times", "ns/op", "B/op", "allocs/op"}
+func main() {
+	compare := flag.Bool("compare", false, "compare two results given as OLD and NEW files")
+	check := flag.Bool("check", false, "compare BASELINE and CURRENT files and exit non-zero on regressions")
+	threshold := flag.String("threshold", "5", "allowed regression in percent as UNIT=PERCENT,... (PERCENT alone is the default)")
+	statName := flag.String("stat", "mean", "statistic to summarize repeated runs (mean or median)")
+	alpha := flag.Float64("alpha", 0.05, "significance level of the Mann-Whitney U test in compare mode")
+	format := flag.String("format", bench.FormatMarkdown, "output format (markdown, csv, tsv, json or html)")
+	procs := flag.Bool("procs", false, "show the metric (-metric) per GOMAXPROCS (go test -cpu) as columns")
+	pivot := flag.String("pivot", "", "pivot dimensions of benchmark names as ROW,COL (e.g., sub1,name)")
+	metric := flag.String("metric", "ns/op", "unit shown in the pivot, procs and trend table and the chart")
+	history := flag.String("history", "", "JSON lines file which each run is appended to")
+	commit := flag.String("commit", "", "commit of the run recorded in the history (default: git HEAD)")
+	ref := flag.String("ref", "", "name or regexp of the reference benchmark to add ratio columns against")
+	include := flag.String("include", "", "show only benchmarks whose name matches the regexp")
+	exclude := flag.String("exclude", "", "hide benchmarks whose name matches the regexp")
+	sortKey := flag.String("sort", "", "sort by name or a unit (e.g., ns/op), prefix with - for descending order")
+	columns := flag.String("columns", "", "comma separated columns to show in order (e.g., name,ns/op,allocs/op)")
+	top := flag.Int("top", 0, "show only the first N benchmarks (after sorting)")
+	human := flag.Bool("human", false, "rescale time and byte metrics, format numbers and add ops/s")
+	chart := flag.String("chart", "", "draw the metric (-metric) as bar charts (text or svg) instead of tables")
+	tmplFile := flag.String("template", "", "text/template file used to render the output instead of tables")
+	tee := flag.Bool("tee", false, "pass the input through to stderr with progress and exit non-zero on FAIL")
+	readme := flag.String("readme", "", "replace the marked section of the markdown file with the output")
+	stale := flag.Bool("stale", false, "do not write -readme but exit non-zero when its section is stale")
+	trend := flag.Int("trend", 0, "show the trend of the last N runs in the history instead of reading results")
+	flag.Parse()
 
-var reNum = regexp.MustCompile(`\d+`)
+	unit := bench.Unit(*metric)
+
+	opts := &bench.Options{
+		// Cells for spreadsheets contain only numbers
+		Plain: *format == bench.FormatCSV || *format == bench.FormatTSV,
+		Alpha: *alpha,
+		Human: *human,
+
+		Reference: *ref,
+	}
+
+	stat, err := bench.ParseStat(*statName)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	var row, col string
+	if *pivot != "" {
+		dims := strings.Split(*pivot, ",")
+		if len(dims) != 2 {
+			log.Fatal("[ERROR] -pivot must be ROW,COL")
+		}
+		row, col = dims[0], dims[1]
+		if row == col {
+			log.Fatal("[ERROR] ROW and COL of -pivot must be different dimensions")
+		}
+	}
+
+	if (*pivot != "" || *procs) && (*compare || *check || *format == bench.FormatJSON) {
+		log.Fatal("[ERROR] -pivot and -procs can not be used with -compare, -check or -format json")
+	}
+
+	if (*sortKey != "" || *top > 0) && (*compare || *check) {
+		log.Fatal("[ERROR] -sort and -top can not be used with -compare or -check")
+	}
+
+	if *chart != "" {
+		if *chart != bench.ChartText && *chart != bench.ChartSVG {
+			log.Fatalf("[ERROR] Unknown chart %q (text or svg)", *chart)
+		}
+		if *pivot != "" || *procs || *trend > 0 {
+			log.Fatal("[ERROR] -chart can not be used with -pivot, -procs or -trend")
+		}
+	}
+
+	if *tmplFile != "" && (*chart != "" || *pivot != "" || *procs || *trend > 0) {
+		log.Fatal("[ERROR] -template can not be used with -chart, -pivot, -procs or -trend")
+	}
+
+	if *tee && (*compare || *check || *trend > 0) {
+		log.Fatal("[ERROR] -tee can not be used with -compare, -check or -trend")
+	}
+
+	if *stale && *readme == "" {
+		log.Fatal("[ERROR] -stale requires -readme")
+	}
+
+	// With -readme, the output is buffered and written to the file
+	var (
+		out io.Writer = os.Stdout
+		buf bytes.Buffer
+	)
+	if *readme != "" {
+		out = &buf
+	}
+
+	var includeRe, excludeRe *regexp.Regexp
+	if *include != "" {
+		if includeRe, err = regexp.Compile(*include); err != nil {
+			log.Fatalf("[ERROR] Invalid -include: %s", err)
+		}
+	}
+	if *exclude != "" {
+		if excludeRe, err = regexp.Compile(*exclude); err != nil {
+			log.Fatalf("[ERROR] Invalid -exclude: %s", err)
+		}
+	}
+
+	var keys []string
+	if *columns != "" {
+		keys = strings.Split(*columns, ",")
+	}
+
+	if *trend > 0 {
+		if *history == "" {
+			log.Fatal("[ERROR] -trend requires -history")
+		}
+
+		entries, err := bench.ReadHistory(*history)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if err := bench.WriteTables(out, *format, bench.NewTrendTables(entries, unit, *trend, opts)); err != nil {
+			log.Fatal(err)
+		}
+		updateReadme(*readme, buf.Bytes(), *stale)
+		return
+	}
+
+	if *compare || *check {
+		if flag.NArg() != 2 {
+			log.Fatal("[Usage] benchtable -compare OLD NEW or benchtable -check BASELINE CURRENT")
+		}
+
+		if *check || *tmplFile != "" {
+			opts.Thresholds, err = bench.ParseThresholds(*threshold)
+			if err != nil {
+				log.Fatal(err)
+			}
+		}
+
+		old, err := parseFile(flag.Arg(0))
+		if err != nil {
+			log.Fatal(err)
+		}
+		new, err := parseFile(flag.Arg(1))
+		if err != nil {
+			log.Fatal(err)
+		}
+		summarize(old, stat, *human)
+		summarize(new, stat, *human)
+		old = match(old, includeRe, excludeRe)
+		new = match(new, includeRe, excludeRe)
+
+		switch {
+		case *tmplFile != "":
+			var tmpl *template.Template
+			if tmpl, err = bench.ParseTemplateFile(*tmplFile, opts); err == nil {
+				err = bench.ExecuteCompareTemplate(out, tmpl, old, new)
+			}
+		case *chart != "":
+			var charts []*bench.Chart
+			for _, pair := range bench.PairSets(old, new) {
+				charts = append(charts, bench.NewCompareChart(pair[0], pair[1], unit, opts))
+			}
+			err = bench.WriteCharts(out, *chart, charts)
+		case *format == bench.FormatJSON:
+			err = bench.WriteCompareJSON(out, old, new, opts)
+		default:
+			var tables []*bench.Table
+			for _, pair := range bench.PairSets(old, new) {
+				tables = append(tables, bench.NewCompareTable(pair[0], pair[1], opts))
+			}
+			selectColumns(tables, keys)
+			err = bench.WriteTables(out, *format, tables)
+		}
+		if err != nil {
+			log.Fatal(err)
+		}
+		updateReadme(*readme, buf.Bytes(), *stale)
+
+		if *check {
+			if vs := bench.Check(old, new, opts.Thresholds, opts.Alpha); len(vs) > 0 {
+				for _, v := range vs {
+					log.Printf("[ERROR] Regression %s", v)
+				}
+				log.Printf("[ERROR] %d metric(s) regressed more than the threshold", len(vs))
+				os.Exit(1)
+			}
+		}
+		return
+	}
 
-func main() {
 	var rd io.Reader
-	if len(os.Args) == 2 {
-		file, err := os.Open(os.Args[1])
+	if flag.NArg() == 1 {
+		file, err := os.Open(flag.Arg(0))
 		if err != nil {
 			log.Fatal(err)
 		}
@@ -40,36 +352,239 @@ func main() {
 	} else {
 		rd = os.Stdin
 	}
-	sc := bufio.NewScanner(rd)
 
-	fmt.Println("| " + strings.Join(items, " | ") + " |")
-	str := "| :"
-	for i, _ := range items {
-		if i == 0 {
-			str += "---: |"
-			continue
+	var t *bench.Tee
+	if *tee {
+		t = &bench.Tee{
+			R: rd,
+			W: os.Stderr,
+			Progress: func(n int) {
+				log.Printf("[INFO] %d benchmark(s) finished", n)
+			},
 		}
-		str += " ---: |"
+		rd = t
 	}
-	fmt.Println(str)
 
-	for sc.Scan() {
-		l := sc.Text()
-		if l == "PASS" {
-			break
+	sets, err := bench.Parse(rd)
+	if err != nil {
+		if t != nil {
+			// Keep passing the rest of the output through
+			io.Copy(ioutil.Discard, t)
 		}
+		log.Fatal(err)
+	}
+	summarize(sets, stat, *human)
+
+	// The history records all results regardless of the selection
+	if *history != "" {
+		c := *commit
+		if c == "" {
+			c = gitCommit()
+		}
+		if err := bench.AppendHistory(*history, bench.NewEntry(sets, c, time.Now())); err != nil {
+			log.Fatal(err)
+		}
+	}
 
-		var buf bytes.Buffer
-		fmt.Fprintf(&buf, "|")
-		data := strings.Split(l, "\t")
-		for j, d := range data {
-			if j == 0 {
-				fmt.Fprintf(&buf, "%s|", strings.TrimSpace(d))
+	sets = match(sets, includeRe, excludeRe)
+	if *sortKey != "" {
+		checkSortKey(sets, *sortKey)
+	}
+	for i, set := range sets {
+		if *sortKey != "" {
+			set.Sort(bench.ParseSortKey(*sortKey))
+		}
+		if *top > 0 {
+			sets[i] = set.Head(*top)
+		}
+	}
+
+	if *ref != "" {
+		found := false
+		for _, set := range sets {
+			if _, err := bench.References(set, *ref); err == nil {
+				found = true
+			}
+		}
+		if !found {
+			log.Fatalf("[ERROR] No benchmark matches reference %q", *ref)
+		}
+	}
+
+	switch {
+	case *tmplFile != "":
+		var tmpl *template.Template
+		if tmpl, err = bench.ParseTemplateFile(*tmplFile, opts); err == nil {
+			err = bench.ExecuteTemplate(out, tmpl, sets)
+		}
+	case *chart != "":
+		var charts []*bench.Chart
+		for _, set := range sets {
+			charts = append(charts, bench.NewChart(set, unit, opts))
+		}
+		err = bench.WriteCharts(out, *chart, charts)
+	case *format == bench.FormatJSON:
+		err = bench.WriteJSON(out, sets)
+	default:
+		if *pivot != "" {
+			pivotDimensions(sets, row, col)
+		}
+
+		var tables []*bench.Table
+		for _, set := range sets {
+			switch {
+			case *pivot != "":
+				tables = append(tables, bench.NewPivotTable(set, row, col, unit, opts))
+				continue
+			case *procs:
+				tables = append(tables, bench.NewProcsTable(set, unit, opts))
 				continue
 			}
+			tables = append(tables, bench.NewTable(set, opts))
+		}
+		if *pivot == "" && !*procs {
+			selectColumns(tables, keys)
+		}
+		err = bench.WriteTables(out, *format, tables)
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+	updateReadme(*readme, buf.Bytes(), *stale)
 
-			fmt.Fprintf(&buf, "%s|", reNum.FindString(d))
+	if t != nil && t.Failed() {
+		log.Print("[ERROR] go test failed")
+		os.Exit(1)
+	}
+}
+
+// updateReadme replaces the marked section of the file with the output.
+// With stale, it exits non-zero when the section is out of date instead.
+// It does nothing when path is empty.
+func updateReadme(path string, content []byte, stale bool) {
+	if path == "" {
+		return
+	}
+
+	changed, err := bench.UpdateSection(path, content, stale)
+	if err != nil {
+		log.Fatalf("[ERROR] %s", err)
+	}
+
+	switch {
+	case changed && stale:
+		log.Printf("[ERROR] Benchmark table in %s is stale, run benchtable with -readme %s", path, path)
+		os.Exit(1)
+	case changed:
+		log.Printf("[INFO] Updated benchmark table in %s", path)
+	default:
+		log.Printf("[INFO] Benchmark table in %s is up to date", path)
+	}
+}
+
+// parseFile parses benchmark results in the given file.
+func parseFile(path string) ([]*bench.Set, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return bench.Parse(file)
+}
+
+// match returns sets of benchmarks which match include and do not
+// match exclude. Sets without benchmarks are dropped.
+func match(sets []*bench.Set, include, exclude *regexp.Regexp) []*bench.Set {
+	if include == nil && exclude == nil {
+		return sets
+	}
+
+	var matched []*bench.Set
+	for _, set := range sets {
+		if s := set.Match(include, exclude); len(s.Benchmarks) > 0 {
+			matched = append(matched, s)
+		}
+	}
+	return matched
+}
+
+// selectColumns keeps only columns of the keys in the tables. It fails
+// when a key matches no table.
+func selectColumns(tables []*bench.Table, keys []string) {
+	if len(keys) == 0 {
+		return
+	}
+
+	for _, k := range keys {
+		found := false
+		for _, t := range tables {
+			if t.HasColumn(k) {
+				found = true
+				break
+			}
+		}
+		if !found && len(tables) > 0 {
+			log.Fatalf("[ERROR] Unknown column %q", k)
 		}
-		fmt.Println(buf.String())
 	}
+
+	for _, t := range tables {
+		t.Select(keys)
+	}
+}
+
+// checkSortKey exits when the key of -sort is neither name nor a unit
+// of the sets.
+func checkSortKey(sets []*bench.Set, s string) {
+	key, _ := bench.ParseSortKey(s)
+	if key == "name" || len(sets) == 0 {
+		return
+	}
+	for _, set := range sets {
+		for _, u := range set.Units {
+			if string(u) == key {
+				return
+			}
+		}
+	}
+	log.Fatalf("[ERROR] Unknown sort key %q", key)
+}
+
+// pivotDimensions exits when the row or the column dimension of -pivot
+// is not in any benchmark of the sets.
+func pivotDimensions(sets []*bench.Set, dims ...string) {
+	for _, d := range dims {
+		found := false
+		for _, set := range sets {
+			if set.HasDimension(d) {
+				found = true
+				break
+			}
+		}
+		if !found && len(sets) > 0 {
+			log.Fatalf("[ERROR] Unknown dimension %q in -pivot", d)
+		}
+	}
+}
+
+// summarize summarizes metrics of the sets. When opsPerSec is true,
+// the ops/s metric derived from ns/op is added before.
+func summarize(sets []*bench.Set, stat bench.Stat, opsPerSec bool) {
+	for _, set := range sets {
+		if opsPerSec {
+			set.AddOpsPerSec()
+		}
+		set.Summarize(stat)
+	}
+}
+
+// gitCommit returns the commit hash of HEAD of the git repository in
+// the current directory. It returns empty string when it's not a git
+// repository.
+func gitCommit() string {
+	out, err := exec.Command("git", "rev-parse", "HEAD").Output()
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(out))
 }
@@ -0,0 +1,157 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"log"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// Batch is a part of the reassignment plan which is executed at once.
+type Batch struct {
+	Partitions []PartitionInfo
+
+	// Bytes is the estimated bytes moved by the batch.
+	Bytes int64
+}
+
+// splitBatches splits the plan into batches which have at most
+// maxPartitions partitions and move at most maxBytes. Zero means no
+// limit. A partition which moves more than maxBytes alone gets its own
+// batch and is warned. moved returns the bytes moved by the partition.
+func splitBatches(infos []PartitionInfo, maxPartitions int, maxBytes int64, moved func(PartitionInfo) int64) []*Batch {
+	var batches []*Batch
+	cur := &Batch{}
+	for _, info := range infos {
+		b := moved(info)
+		if maxBytes > 0 && b > maxBytes {
+			log.Printf("[WARN] %s moves %s which exceeds -batch-bytes %s",
+				partitionKey(info.Topic, info.Partition), formatBytes(b), formatBytes(maxBytes))
+		}
+		full := (maxPartitions > 0 && len(cur.Partitions) >= maxPartitions) ||
+			(maxBytes > 0 && cur.Bytes+b > maxBytes)
+		if full && len(cur.Partitions) > 0 {
+			batches = append(batches, cur)
+			cur = &Batch{}
+		}
+		cur.Partitions = append(cur.Partitions, info)
+		cur.Bytes += b
+	}
+	if len(cur.Partitions) > 0 {
+		batches = append(batches, cur)
+	}
+	return batches
+}
+
+// batchFile returns the file name of the i-th (0-origin) batch of the
+// plan whose checksum is sum.
+func batchFile(sum string, i int) string {
+	return fmt.Sprintf("reassign-%s-%03d.json", sum, i+1)
+}
+
+// runScript is the script which executes batches in order. Each batch is
+// executed with the throttle and the next one starts after --verify
+// reports that all partitions of the batch are reassigned (which also
+// removes the throttle).
+const runScript = `#!/bin/sh
+# Generated by kafka-topics-move. It executes reassignment batches one by
+# one with the replication throttle and waits for each to complete.
+#
+# Set ZK (e.g., ZK=zk:2181 ./run.sh) or CONNECT (e.g.,
+# CONNECT="--bootstrap-server kafka:9092" ./run.sh).
+#
+# To revert the plan, execute %s
+# with kafka-reassign-partitions.sh --execute.
+set -eu
+
+cd "$(dirname "$0")"
+
+CONNECT=${CONNECT:-"--zookeeper ${ZK:?ZK or CONNECT must be set}"}
+THROTTLE=${THROTTLE:-%d}
+INTERVAL=${INTERVAL:-30}
+
+run() {
+	echo "==> Executing $1 ($2)"
+	kafka-reassign-partitions.sh $CONNECT --reassignment-json-file "$1" --execute --throttle "$THROTTLE"
+
+	while :; do
+		sleep "$INTERVAL"
+		out=$(kafka-reassign-partitions.sh $CONNECT --reassignment-json-file "$1" --verify)
+		echo "$out"
+		if echo "$out" | grep -q "failed"; then
+			echo "==> Reassignment of $1 failed" >&2
+			exit 1
+		fi
+		if ! echo "$out" | grep -q "in progress"; then
+			break
+		fi
+	done
+}
+
+%s
+echo "==> All batches are reassigned"
+`
+
+// writeBatches writes each batch of the plan whose checksum is sum to
+// a numbered file and the script which executes them (run.sh) in the
+// directory.
+func writeBatches(dir, sum string, batches []*Batch, throttle int64) error {
+	var runs []string
+	for i, batch := range batches {
+		name := batchFile(sum, i)
+		err := writeReassignment(filepath.Join(dir, name), &Reassignment{
+			Version:    defaultVersion,
+			Partitions: batch.Partitions,
+		})
+		if err != nil {
+			return err
+		}
+		runs = append(runs, fmt.Sprintf("run %s %q", name,
+			fmt.Sprintf("%d partitions, %s", len(batch.Partitions), formatBytes(batch.Bytes))))
+	}
+
+	script := fmt.Sprintf(runScript, rollbackFile(sum), throttle, strings.Join(runs, "\n"))
+	return ioutil.WriteFile(filepath.Join(dir, "run.sh"), []byte(script), 0755)
+}
+
+// byteUnits are units of byte sizes from the largest.
+var byteUnits = []struct {
+	name string
+	size int64
+}{
+	{"TiB", 1 << 40}, {"GiB", 1 << 30}, {"MiB", 1 << 20}, {"KiB", 1 << 10},
+	{"TB", 1e12}, {"GB", 1e9}, {"MB", 1e6}, {"KB", 1e3}, {"B", 1},
+}
+
+// parseBytes parses a byte size like "500MB", "10GiB" or "1024".
+func parseBytes(s string) (int64, error) {
+	s = strings.TrimSpace(s)
+	for _, u := range byteUnits {
+		if !strings.HasSuffix(s, u.name) {
+			continue
+		}
+		v, err := strconv.ParseFloat(strings.TrimSpace(strings.TrimSuffix(s, u.name)), 64)
+		if err != nil || v < 0 {
+			return 0, fmt.Errorf("invalid size %q", s)
+		}
+		return int64(v * float64(u.size)), nil
+	}
+
+	v, err := strconv.ParseInt(s, 10, 64)
+	if err != nil || v < 0 {
+		return 0, fmt.Errorf("invalid size %q", s)
+	}
+	return v, nil
+}
+
+// formatBytes formats the byte size in binary units (e.g., 1.5 GiB).
+func formatBytes(b int64) string {
+	for _, u := range byteUnits[:4] {
+		if b >= u.size {
+			return fmt.Sprintf("%.1f %s", float64(b)/float64(u.size), u.name)
+		}
+	}
+	return fmt.Sprintf("%d B", b)
+}
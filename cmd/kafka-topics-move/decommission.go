@@ -0,0 +1,220 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+)
+
+// BrokerGain is what a surviving broker gains by decommission.
+type BrokerGain struct {
+	Broker     int
+	Partitions int
+	Leaders    int
+	Bytes      int64
+}
+
+// decommission moves only replicas on the removed brokers. Each replica
+// goes to the least loaded eligible broker (by bytes with sizes, then by
+// the number of replicas) on a rack which other replicas of the
+// partition do not use. The new replica takes the position of the
+// removed one, so the order of replicas (the preferred leader) is kept.
+// Eligible brokers are the given brokers or, when they are empty, all
+// brokers in the current assignment except the removed ones. all is
+// the current assignment of all partitions used to compute loads.
+//
+// When strict is true, it fails if a replica can not be placed on a
+// distinct rack. Otherwise it is placed on any eligible broker.
+func decommission(partitions, all []*Partition, removed, brokers []int, racks Racks, strict bool, sizes Sizes) ([]PartitionInfo, []*BrokerGain, error) {
+	isRemoved := make(map[int]bool)
+	for _, b := range removed {
+		isRemoved[b] = true
+	}
+
+	if len(brokers) == 0 {
+		seen := make(map[int]bool)
+		for _, p := range all {
+			for _, b := range p.Replicas {
+				if !seen[b] && !isRemoved[b] {
+					seen[b] = true
+					brokers = append(brokers, b)
+				}
+			}
+		}
+	}
+	eligible := make([]int, 0, len(brokers))
+	for _, b := range brokers {
+		if isRemoved[b] {
+			return nil, nil, fmt.Errorf("broker %d is both removed and a target", b)
+		}
+		eligible = append(eligible, b)
+	}
+	sort.Ints(eligible)
+	if len(eligible) == 0 {
+		return nil, nil, fmt.Errorf("no brokers left after removing %s", formatBrokers(removed))
+	}
+	if err := racks.check(eligible); err != nil {
+		return nil, nil, err
+	}
+
+	bytes := make(map[int]int64)
+	counts := make(map[int]int)
+	for _, p := range all {
+		size, _ := sizes.size(p.Topic, p.Partition)
+		for _, b := range p.Replicas {
+			bytes[b] += size
+			counts[b]++
+		}
+	}
+
+	gains := make(map[int]*BrokerGain)
+	for _, b := range eligible {
+		gains[b] = &BrokerGain{Broker: b}
+	}
+
+	var (
+		infos  []PartitionInfo
+		report []string
+	)
+	for _, p := range partitions {
+		size, _ := sizes.size(p.Topic, p.Partition)
+
+		replicas := make([]int, len(p.Replicas))
+		copy(replicas, p.Replicas)
+		for i, r := range replicas {
+			if !isRemoved[r] {
+				continue
+			}
+
+			// Racks and brokers used by the other replicas
+			usedRacks := make(map[string]bool)
+			usedBrokers := make(map[int]bool)
+			for j, o := range replicas {
+				if j != i && !isRemoved[o] {
+					usedRacks[racks.rack(o)] = true
+				}
+				usedBrokers[o] = true
+			}
+
+			// Prefer a distinct rack, then less bytes and less replicas
+			best, distinct := -1, false
+			better := func(b int, d bool) bool {
+				switch {
+				case best < 0:
+					return true
+				case d != distinct:
+					return d
+				case bytes[b] != bytes[best]:
+					return bytes[b] < bytes[best]
+				}
+				return counts[b] < counts[best]
+			}
+			for _, b := range eligible {
+				if usedBrokers[b] {
+					continue
+				}
+				if d := !usedRacks[racks.rack(b)]; better(b, d) {
+					best, distinct = b, d
+				}
+			}
+
+			if best < 0 {
+				return nil, nil, fmt.Errorf("no eligible broker for the replica of %s on broker %d",
+					partitionKey(p.Topic, p.Partition), r)
+			}
+			if !distinct && racks != nil {
+				report = append(report, fmt.Sprintf("%s (replica on broker %d)", partitionKey(p.Topic, p.Partition), r))
+			}
+
+			replicas[i] = best
+			bytes[best] += size
+			counts[best]++
+
+			g := gains[best]
+			g.Partitions++
+			g.Bytes += size
+			if i == 0 {
+				g.Leaders++
+			}
+		}
+
+		if equalReplicas(replicas, p.Replicas) {
+			continue
+		}
+		infos = append(infos, PartitionInfo{
+			Topic:     p.Topic,
+			Partition: p.Partition,
+			Replicas:  replicas,
+		})
+	}
+
+	if len(report) > 0 {
+		if strict {
+			return nil, nil, fmt.Errorf("%d replica(s) can not be placed on a distinct rack:\n  %s",
+				len(report), strings.Join(report, "\n  "))
+		}
+		log.Printf("[WARN] %d replica(s) share a rack with other replicas of the partition", len(report))
+	}
+
+	result := make([]*BrokerGain, 0, len(eligible))
+	for _, b := range eligible {
+		result = append(result, gains[b])
+	}
+	return infos, result, nil
+}
+
+// remainingReplicas returns partitions skipped by filters which still
+// have replicas on the removed brokers.
+func remainingReplicas(all, partitions []*Partition, removed []int) []string {
+	planned := make(map[string]bool)
+	for _, p := range partitions {
+		planned[partitionKey(p.Topic, p.Partition)] = true
+	}
+
+	var keys []string
+	for _, p := range all {
+		key := partitionKey(p.Topic, p.Partition)
+		if planned[key] {
+			continue
+		}
+		for _, b := range p.Replicas {
+			if containsInt(removed, b) {
+				keys = append(keys, key)
+				break
+			}
+		}
+	}
+	return keys
+}
+
+// logGains logs partitions and leaders each surviving broker gains.
+// Bytes are logged only when sizes of partitions are known.
+func logGains(gains []*BrokerGain, bytes bool) {
+	for _, g := range gains {
+		if bytes {
+			log.Printf("[INFO] Broker %d gains %d partitions (%d leaders), %s",
+				g.Broker, g.Partitions, g.Leaders, formatBytes(g.Bytes))
+			continue
+		}
+		log.Printf("[INFO] Broker %d gains %d partitions (%d leaders)", g.Broker, g.Partitions, g.Leaders)
+	}
+}
+
+func containsInt(s []int, v int) bool {
+	for _, x := range s {
+		if x == v {
+			return true
+		}
+	}
+	return false
+}
+
+// formatBrokers formats broker IDs like "1,2,3".
+func formatBrokers(brokers []int) string {
+	s := make([]string, len(brokers))
+	for i, b := range brokers {
+		s[i] = fmt.Sprint(b)
+	}
+	return strings.Join(s, ",")
+}
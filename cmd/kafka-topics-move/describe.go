@@ -0,0 +1,122 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Partition is a partition in kafka-topics.sh --describe output.
+type Partition struct {
+	Topic     string
+	Partition int
+	Leader    int
+	Replicas  []int
+	Isr       []int
+	Deleted   bool
+}
+
+// parseFields parses a line of kafka-topics.sh --describe output like
+// "Topic: topic1	Partition: 0	Leader: 1	Replicas: 1,2	Isr: 1,2". Old
+// versions print fields without a space after the colon.
+func parseFields(line string) map[string]string {
+	fields := make(map[string]string)
+	for _, f := range strings.Split(line, "\t") {
+		i := strings.Index(f, ":")
+		if i < 0 {
+			continue
+		}
+		fields[strings.TrimSpace(f[:i])] = strings.TrimSpace(f[i+1:])
+	}
+	return fields
+}
+
+// parseBrokerList parses a comma separated list of broker IDs like
+// "1,2,3". Duplicate IDs are rejected.
+func parseBrokerList(s string) ([]int, error) {
+	var brokers []int
+	seen := make(map[int]bool)
+	for _, f := range strings.Split(s, ",") {
+		f = strings.TrimSpace(f)
+		if f == "" {
+			continue
+		}
+		id, err := strconv.Atoi(f)
+		if err != nil {
+			return nil, fmt.Errorf("invalid broker ID %q", f)
+		}
+		if seen[id] {
+			return nil, fmt.Errorf("duplicate broker ID %d", id)
+		}
+		seen[id] = true
+		brokers = append(brokers, id)
+	}
+	return brokers, nil
+}
+
+// parseDescribe parses kafka-topics.sh --describe output. Partitions
+// are sorted by topic and partition.
+func parseDescribe(r io.Reader) ([]*Partition, error) {
+	var partitions []*Partition
+
+	// deleted is topics marked for deletion on the topic line
+	deleted := make(map[string]bool)
+
+	scnr := bufio.NewScanner(r)
+	for n := 1; scnr.Scan(); n++ {
+		fields := parseFields(scnr.Text())
+		topic := fields["Topic"]
+		if topic == "" {
+			continue
+		}
+
+		marked := fields["MarkedForDeletion"] == "true"
+		if _, ok := fields["Partition"]; !ok {
+			// Topic line (e.g., PartitionCount and ReplicationFactor)
+			if marked {
+				deleted[topic] = true
+			}
+			continue
+		}
+
+		p := &Partition{
+			Topic:   topic,
+			Leader:  -1,
+			Deleted: marked || deleted[topic],
+		}
+
+		var err error
+		if p.Partition, err = strconv.Atoi(fields["Partition"]); err != nil {
+			return nil, fmt.Errorf("line %d: invalid partition %q", n, fields["Partition"])
+		}
+		if leader, err := strconv.Atoi(fields["Leader"]); err == nil {
+			// Leader is "none" when no replica is alive
+			p.Leader = leader
+		}
+		if p.Replicas, err = parseBrokerList(fields["Replicas"]); err != nil {
+			return nil, fmt.Errorf("line %d: %s", n, err)
+		}
+		if len(p.Replicas) == 0 {
+			return nil, fmt.Errorf("line %d: no replicas of %s-%d", n, topic, p.Partition)
+		}
+		if p.Isr, err = parseBrokerList(fields["Isr"]); err != nil {
+			return nil, fmt.Errorf("line %d: %s", n, err)
+		}
+		partitions = append(partitions, p)
+	}
+
+	if err := scnr.Err(); err != nil {
+		return nil, err
+	}
+
+	sort.SliceStable(partitions, func(i, j int) bool {
+		if partitions[i].Topic != partitions[j].Topic {
+			return partitions[i].Topic < partitions[j].Topic
+		}
+		return partitions[i].Partition < partitions[j].Partition
+	})
+	return partitions, nil
+}
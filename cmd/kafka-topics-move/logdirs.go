@@ -0,0 +1,115 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// LogDirs is kafka-log-dirs.sh --describe output.
+type LogDirs struct {
+	Version int `json:"version"`
+	Brokers []struct {
+		Broker  int `json:"broker"`
+		LogDirs []struct {
+			LogDir     string `json:"logDir"`
+			Error      string `json:"error"`
+			Partitions []struct {
+				Partition string `json:"partition"`
+				Size      int64  `json:"size"`
+				OffsetLag int64  `json:"offsetLag"`
+				IsFuture  bool   `json:"isFuture"`
+			} `json:"partitions"`
+		} `json:"logDirs"`
+	} `json:"brokers"`
+}
+
+// Sizes is sizes of partitions in bytes per broker keyed by partition
+// key (see partitionKey).
+type Sizes map[string]map[int]int64
+
+// parseLogDirs parses kafka-log-dirs.sh --describe output. The output
+// has progress lines before the JSON line.
+func parseLogDirs(r io.Reader) (Sizes, error) {
+	scnr := bufio.NewScanner(r)
+	scnr.Buffer(nil, 1<<30)
+	for scnr.Scan() {
+		line := strings.TrimSpace(scnr.Text())
+		if !strings.HasPrefix(line, "{") {
+			continue
+		}
+
+		var dirs LogDirs
+		if err := json.Unmarshal([]byte(line), &dirs); err != nil {
+			return nil, err
+		}
+
+		sizes := make(Sizes)
+		for _, b := range dirs.Brokers {
+			for _, d := range b.LogDirs {
+				for _, p := range d.Partitions {
+					if p.IsFuture {
+						// Replica being moved between log dirs
+						continue
+					}
+					if sizes[p.Partition] == nil {
+						sizes[p.Partition] = make(map[int]int64)
+					}
+					sizes[p.Partition][b.Broker] += p.Size
+				}
+			}
+		}
+		return sizes, nil
+	}
+
+	if err := scnr.Err(); err != nil {
+		return nil, err
+	}
+	return nil, fmt.Errorf("no JSON in kafka-log-dirs.sh output")
+}
+
+// readLogDirs reads kafka-log-dirs.sh --describe output from the file.
+func readLogDirs(path string) (Sizes, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return parseLogDirs(f)
+}
+
+// size returns the size of the partition. It is the largest one among
+// replicas since followers may lag behind. It returns false when the
+// size is unknown.
+func (s Sizes) size(topic string, partition int) (int64, bool) {
+	replicas, ok := s[partitionKey(topic, partition)]
+	if !ok {
+		return 0, false
+	}
+
+	var max int64
+	for _, v := range replicas {
+		if v > max {
+			max = v
+		}
+	}
+	return max, true
+}
+
+// usage returns the disk usage of each broker by partitions which are
+// not in the given set of partition keys.
+func (s Sizes) usage(exclude map[string]bool) map[int]int64 {
+	usage := make(map[int]int64)
+	for key, replicas := range s {
+		if exclude[key] {
+			continue
+		}
+		for b, v := range replicas {
+			usage[b] += v
+		}
+	}
+	return usage
+}
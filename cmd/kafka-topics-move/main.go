@@ -3,11 +3,79 @@ Command 'kafka-topics-move' generates a json which is used for
 input of kafka-reassign-partitions.sh. 'kafka-topics-move' accepts
 list of topics generated by kafka-topics.sh command.
 
-  $ kafka-topics.sh --list --zookeeper $ZK | grep $TOPIC_RE | kafka-topics-move > out.json
+  $ kafka-topics.sh --list --zookeeper $ZK | kafka-topics-move -include $TOPIC_RE > out.json
+
+Topics marked for deletion are skipped unless -keep-deleted is given.
+Topics can be filtered by regular expressions via -include and -exclude
+and internal topics (e.g., __consumer_offsets) are skipped with
+-skip-internal.
+
+  $ kafka-topics.sh --list --zookeeper $ZK | kafka-topics-move -skip-internal -exclude '^test-' > out.json
+
+With -brokers, it generates the complete reassignment plan offline
+instead. It reads kafka-topics.sh --describe output and assigns replicas
+of each partition to the target brokers in round-robin (the same input
+always produces the same plan). The output is the input of
+kafka-reassign-partitions.sh --execute.
+
+  $ kafka-topics.sh --describe --zookeeper $ZK | kafka-topics-move -brokers 4,5,6 > plan.json
+
+To spread replicas of each partition across racks (e.g., availability
+zones), give the broker to rack mapping via -racks. The file has a broker
+ID and its rack per line (e.g., "4 us-east-1a") or is the output of
+kafka-configs.sh --describe --entity-type brokers --all which includes
+broker.rack. Leaders are rotated across racks too. With -strict-racks,
+it fails when the replication factor is larger than the number of racks.
+
+  $ kafka-topics.sh --describe --zookeeper $ZK | kafka-topics-move -brokers 4,5,6 -racks racks.txt -strict-racks > plan.json
+
+To avoid flooding the cluster, the plan can be split into numbered
+batch files (reassign-CHECKSUM-001.json, ...) in the directory given by
+-out. Each batch has at most -batch-partitions partitions and moves at
+most -batch-bytes, estimated from -partition-size per replica moved. The
+run.sh script in the directory executes batches one by one with
+--throttle and waits for --verify to succeed before the next one.
+
+  $ kafka-topics.sh --describe --zookeeper $ZK | kafka-topics-move -brokers 4,5,6 -out plan -batch-partitions 50 -batch-bytes 100GiB
+  $ ZK=$ZK THROTTLE=50000000 plan/run.sh
+
+Partition counts don't reflect the real data movement. With -log-dirs,
+sizes of partitions are read from kafka-log-dirs.sh --describe output
+(-partition-size is used for partitions which are not in it). Replicas
+are then placed from the largest partition on the broker with the least
+disk usage and batches are capped by the real bytes. The summary shows
+bytes each topic and batch moves and warns about topics with partitions
+larger than -huge-partition (without -log-dirs, it only shows replicas
+each topic moves).
+
+  $ kafka-log-dirs.sh --describe --bootstrap-server $KAFKA > log-dirs.txt
+  $ kafka-topics.sh --describe --zookeeper $ZK | kafka-topics-move -brokers 4,5,6 -log-dirs log-dirs.txt -out plan -batch-bytes 100GiB
+
+Partitions whose replicas do not change are left out of the plan. The
+rollback plan which restores the current replicas of exactly the changed
+partitions is always written as rollback-CHECKSUM.json, in the -out
+directory or the current directory. CHECKSUM is the checksum of the plan
+and is also in names of the batch files (reassign-CHECKSUM-001.json, ...)
+and logged, so the plan and its rollback can be matched.
+
+  $ kafka-reassign-partitions.sh --zookeeper $ZK --reassignment-json-file rollback-CHECKSUM.json --execute
+
+To retire brokers, give their IDs via -remove. Only replicas on them are
+moved, each to the least loaded eligible broker (by bytes with -log-dirs,
+otherwise by the number of replicas) on a rack which other replicas of
+the partition do not use. The new replica takes the place of the removed
+one, so the preferred leader order is kept. Eligible brokers are ones in
+-brokers or all other brokers in the current assignment. It logs how many
+partitions and leaders each surviving broker gains.
+
+  $ kafka-topics.sh --describe --zookeeper $ZK | kafka-topics-move -remove 3 -racks racks.txt > plan.json
 
 To run it with test data
 
-  $ cat testdata/kafka-topics.txt | go run main.go
+  $ cat testdata/kafka-topics.txt | go run *.go
+  $ cat testdata/kafka-topics-describe.txt | go run *.go -brokers 4,5,6
+  $ cat testdata/kafka-topics-describe.txt | go run *.go -brokers 4,5,6 -log-dirs testdata/kafka-log-dirs.txt
+  $ cat testdata/kafka-topics-describe.txt | go run *.go -remove 3 -brokers 1,2,4
 
 To install it,
 
@@ -23,9 +91,12 @@ package main
 import (
 	"bufio"
 	"encoding/json"
+	"flag"
 	"fmt"
 	"log"
 	"os"
+	"path/filepath"
+	"regexp"
 	"strings"
 )
 
@@ -42,11 +113,150 @@ type TopicInfo struct {
 
 const defaultVersion = 1
 
+// markedForDeletion is the suffix kafka-topics.sh --list adds to topics
+// which are being deleted.
+const markedForDeletion = "marked for deletion"
+
+// internalPrefix is the prefix of internal topics (e.g.,
+// __consumer_offsets and __transaction_state).
+const internalPrefix = "__"
+
+// Filter decides which topics are moved.
+type Filter struct {
+	Include      *regexp.Regexp
+	Exclude      *regexp.Regexp
+	KeepDeleted  bool
+	SkipInternal bool
+}
+
+// Match reports whether the topic should be moved. It returns the reason
+// when the topic is skipped.
+func (f *Filter) Match(topic string, deleted bool) (bool, string) {
+	if deleted && !f.KeepDeleted {
+		return false, markedForDeletion
+	}
+	if f.SkipInternal && strings.HasPrefix(topic, internalPrefix) {
+		return false, "internal topic"
+	}
+	if f.Include != nil && !f.Include.MatchString(topic) {
+		return false, "not matched by -include"
+	}
+	if f.Exclude != nil && f.Exclude.MatchString(topic) {
+		return false, "matched by -exclude"
+	}
+	return true, ""
+}
+
+// parseTopicLine parses a line of kafka-topics.sh --list output like
+// "topic4 - marked for deletion". It returns the empty topic for an
+// empty line.
+func parseTopicLine(line string) (string, bool) {
+	fields := strings.Fields(line)
+	if len(fields) == 0 {
+		return "", false
+	}
+	return fields[0], strings.Contains(line, markedForDeletion)
+}
+
 func main() {
 	os.Exit(_main())
 }
 
 func _main() int {
+	keepDeleted := flag.Bool("keep-deleted", false, "keep topics marked for deletion")
+	include := flag.String("include", "", "move only topics which match the regexp")
+	exclude := flag.String("exclude", "", "skip topics which match the regexp")
+	skipInternal := flag.Bool("skip-internal", false, "skip internal topics (e.g., __consumer_offsets)")
+	brokerList := flag.String("brokers", "", "comma separated target broker IDs to generate the plan from kafka-topics.sh --describe output")
+	rackFile := flag.String("racks", "", "broker to rack mapping file for rack-aware placement")
+	strictRacks := flag.Bool("strict-racks", false, "fail when the replication factor is larger than the number of racks")
+	out := flag.String("out", "", "directory to write the plan split into batches and run.sh")
+	batchPartitions := flag.Int("batch-partitions", 0, "maximum number of partitions per batch")
+	batchBytes := flag.String("batch-bytes", "0", "maximum estimated bytes moved per batch (e.g., 100GiB)")
+	partitionSize := flag.String("partition-size", "1GiB", "estimated size of a partition used by -batch-bytes")
+	throttle := flag.String("throttle", "50MB", "replication throttle in bytes/sec used by run.sh")
+	logDirs := flag.String("log-dirs", "", "kafka-log-dirs.sh --describe output to balance disk usage by partition sizes")
+	hugePartition := flag.String("huge-partition", "10GiB", "partition size which is reported as huge in the summary")
+	remove := flag.String("remove", "", "comma separated broker IDs to decommission (moves only their replicas)")
+	flag.Parse()
+
+	filter := &Filter{
+		KeepDeleted:  *keepDeleted,
+		SkipInternal: *skipInternal,
+	}
+
+	var err error
+	if *include != "" {
+		if filter.Include, err = regexp.Compile(*include); err != nil {
+			log.Printf("[ERROR] Invalid -include: %s", err)
+			return 1
+		}
+	}
+	if *exclude != "" {
+		if filter.Exclude, err = regexp.Compile(*exclude); err != nil {
+			log.Printf("[ERROR] Invalid -exclude: %s", err)
+			return 1
+		}
+	}
+
+	if *strictRacks && *rackFile == "" {
+		log.Printf("[ERROR] -strict-racks can not be used without -racks")
+		return 1
+	}
+
+	if *brokerList != "" || *remove != "" {
+		brokers, err := parseBrokerList(*brokerList)
+		if err != nil {
+			log.Printf("[ERROR] Invalid -brokers: %s", err)
+			return 1
+		}
+		removed, err := parseBrokerList(*remove)
+		if err != nil {
+			log.Printf("[ERROR] Invalid -remove: %s", err)
+			return 1
+		}
+
+		opts := &PlanOptions{
+			Brokers:         brokers,
+			Remove:          removed,
+			StrictRacks:     *strictRacks,
+			Dir:             *out,
+			BatchPartitions: *batchPartitions,
+		}
+		if *rackFile != "" {
+			if opts.Racks, err = readRacks(*rackFile); err != nil {
+				log.Printf("[ERROR] Failed to read -racks: %s", err)
+				return 1
+			}
+		}
+		if *logDirs != "" {
+			if opts.Sizes, err = readLogDirs(*logDirs); err != nil {
+				log.Printf("[ERROR] Failed to read -log-dirs: %s", err)
+				return 1
+			}
+		}
+		for _, f := range []struct {
+			name string
+			s    string
+			v    *int64
+		}{
+			{"-batch-bytes", *batchBytes, &opts.BatchBytes},
+			{"-partition-size", *partitionSize, &opts.PartitionSize},
+			{"-throttle", *throttle, &opts.Throttle},
+			{"-huge-partition", *hugePartition, &opts.HugePartition},
+		} {
+			if *f.v, err = parseBytes(f.s); err != nil {
+				log.Printf("[ERROR] Invalid %s: %s", f.name, err)
+				return 1
+			}
+		}
+		if opts.Dir == "" && (opts.BatchPartitions > 0 || opts.BatchBytes > 0) {
+			log.Printf("[ERROR] -batch-partitions and -batch-bytes require -out")
+			return 1
+		}
+		return _plan(filter, opts)
+	}
+
 	// Collect all target topics to buf
 	topics := make([]TopicInfo, 0)
 
@@ -55,14 +265,24 @@ func _main() int {
 	scnr := bufio.NewScanner(os.Stdin)
 	for scnr.Scan() {
 		// Some topic has additional infromation like `mark for deletion`
-		topic := strings.Split(scnr.Text(), " ")[0]
+		topic, deleted := parseTopicLine(scnr.Text())
 		if len(topic) == 0 {
 			// Skip empty topic
 			continue
 		}
+
+		if ok, reason := filter.Match(topic, deleted); !ok {
+			log.Printf("[INFO] Skip %s (%s)", topic, reason)
+			continue
+		}
 		topics = append(topics, TopicInfo{Topic: topic})
 	}
 
+	if err := scnr.Err(); err != nil {
+		log.Printf("[ERROR] Failed to read inputs %s", err)
+		return 1
+	}
+
 	// Genreate json
 	buf, err := json.MarshalIndent(&Instruction{
 		Topics:  topics,
@@ -76,3 +296,171 @@ func _main() int {
 	fmt.Printf("%s\n", string(buf))
 	return 0
 }
+
+// PlanOptions configures how the reassignment plan is generated.
+type PlanOptions struct {
+	Brokers     []int
+	Racks       Racks
+	StrictRacks bool
+
+	// Remove is brokers to decommission. When it is not empty, only
+	// their replicas are moved and Brokers are eligible brokers (all
+	// other brokers when Brokers is empty).
+	Remove []int
+
+	// Dir is the directory which batches and run.sh are written to.
+	// The plan is written to STDOUT when it is empty.
+	Dir             string
+	BatchPartitions int
+	BatchBytes      int64
+	PartitionSize   int64
+	Throttle        int64
+
+	// Sizes is sizes of partitions from kafka-log-dirs.sh. When it is
+	// not nil, replicas are placed to balance disk usage.
+	Sizes         Sizes
+	HugePartition int64
+}
+
+// _plan generates the reassignment plan from kafka-topics.sh --describe
+// output on STDIN.
+func _plan(filter *Filter, opts *PlanOptions) int {
+	log.Printf("Waiting kafka-topics.sh --describe output from STDIN...")
+	all, err := parseDescribe(os.Stdin)
+	if err != nil {
+		log.Printf("[ERROR] Failed to parse inputs %s", err)
+		return 1
+	}
+
+	var partitions []*Partition
+	skipped := make(map[string]bool)
+	for _, p := range all {
+		if ok, reason := filter.Match(p.Topic, p.Deleted); !ok {
+			if !skipped[p.Topic] {
+				log.Printf("[INFO] Skip %s (%s)", p.Topic, reason)
+				skipped[p.Topic] = true
+			}
+			continue
+		}
+		partitions = append(partitions, p)
+	}
+
+	current := make(map[string]*Partition)
+	planned := make(map[string]bool)
+	for _, p := range partitions {
+		current[partitionKey(p.Topic, p.Partition)] = p
+		planned[partitionKey(p.Topic, p.Partition)] = true
+	}
+
+	var infos []PartitionInfo
+	if len(opts.Remove) > 0 {
+		for _, key := range remainingReplicas(all, partitions, opts.Remove) {
+			log.Printf("[WARN] %s is skipped but has replicas on broker(s) %s", key, formatBrokers(opts.Remove))
+		}
+
+		var gains []*BrokerGain
+		infos, gains, err = decommission(partitions, all, opts.Remove, opts.Brokers,
+			opts.Racks, opts.StrictRacks, opts.Sizes)
+		if err != nil {
+			log.Printf("[ERROR] Failed to decommission broker(s) %s: %s", formatBrokers(opts.Remove), err)
+			return 1
+		}
+		logGains(gains, opts.Sizes != nil)
+	} else if opts.Sizes != nil {
+		// Partitions out of the plan stay where they are
+		infos, err = assignBySize(partitions, opts.Brokers, opts.Racks, opts.StrictRacks,
+			opts.Sizes, opts.PartitionSize, opts.Sizes.usage(planned))
+	} else {
+		infos, err = assign(partitions, opts.Brokers, opts.Racks, opts.StrictRacks)
+	}
+	if err != nil {
+		log.Printf("[ERROR] Failed to assign replicas: %s", err)
+		return 1
+	}
+
+	// Partitions which stay as they are do not need to be reassigned
+	changed := changedPartitions(infos, current)
+	if n := len(infos) - len(changed); n > 0 {
+		log.Printf("[INFO] Skip %d partitions whose replicas do not change", n)
+	}
+	infos = changed
+	if len(infos) == 0 {
+		log.Printf("[INFO] No partitions to reassign")
+		return 0
+	}
+
+	plan := &Reassignment{
+		Version:    defaultVersion,
+		Partitions: infos,
+	}
+	sum, err := checksum(plan)
+	if err != nil {
+		log.Printf("[ERROR] Failed to marshal json %s", err)
+		return 1
+	}
+	rollback, err := rollbackPlan(infos, current)
+	if err != nil {
+		log.Printf("[ERROR] Failed to generate rollback: %s", err)
+		return 1
+	}
+
+	// Sizes of partitions which are not in kafka-log-dirs.sh output are
+	// estimated by -partition-size
+	size := func(info PartitionInfo) int64 {
+		if v, ok := opts.Sizes.size(info.Topic, info.Partition); ok {
+			return v
+		}
+		return opts.PartitionSize
+	}
+	replicas := func(info PartitionInfo) int {
+		return movedReplicas(current[partitionKey(info.Topic, info.Partition)], info)
+	}
+	moved := func(info PartitionInfo) int64 {
+		return int64(replicas(info)) * size(info)
+	}
+	logSummary(summarize(infos, replicas, size), opts.HugePartition, opts.Sizes != nil)
+
+	if opts.Dir != "" {
+		batches := splitBatches(infos, opts.BatchPartitions, opts.BatchBytes, moved)
+
+		if err := os.MkdirAll(opts.Dir, 0755); err != nil {
+			log.Printf("[ERROR] Failed to create %s: %s", opts.Dir, err)
+			return 1
+		}
+		if err := writeBatches(opts.Dir, sum, batches, opts.Throttle); err != nil {
+			log.Printf("[ERROR] Failed to write batches: %s", err)
+			return 1
+		}
+		path, err := writeRollback(opts.Dir, sum, rollback)
+		if err != nil {
+			log.Printf("[ERROR] Failed to write rollback: %s", err)
+			return 1
+		}
+
+		for i, batch := range batches {
+			log.Printf("[INFO] %s: %d partitions, %s", batchFile(sum, i), len(batch.Partitions), formatBytes(batch.Bytes))
+		}
+		log.Printf("[INFO] Wrote the rollback to %s", path)
+		log.Printf("[INFO] Generated the plan %s of %d partitions in %d batches, run %s",
+			sum, len(infos), len(batches), filepath.Join(opts.Dir, "run.sh"))
+		return 0
+	}
+
+	buf, err := json.MarshalIndent(plan, "", "  ")
+	if err != nil {
+		log.Printf("[ERROR] Failed to marshal json %s", err)
+		return 1
+	}
+
+	// The plan goes to STDOUT, so the rollback is written to the current
+	// directory
+	path, err := writeRollback(".", sum, rollback)
+	if err != nil {
+		log.Printf("[ERROR] Failed to write rollback: %s", err)
+		return 1
+	}
+	log.Printf("[INFO] Wrote the rollback to %s", path)
+	log.Printf("[INFO] Generated the plan %s of %d partitions", sum, len(infos))
+	fmt.Printf("%s\n", string(buf))
+	return 0
+}
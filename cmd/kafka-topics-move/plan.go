@@ -0,0 +1,266 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+)
+
+// Reassignment is output format of the reassignment plan. It is the
+// input of kafka-reassign-partitions.sh --execute.
+type Reassignment struct {
+	Version    int             `json:"version"`
+	Partitions []PartitionInfo `json:"partitions"`
+}
+
+// PartitionInfo contains replicas of a partition. The first replica is
+// the preferred leader.
+type PartitionInfo struct {
+	Topic     string `json:"topic"`
+	Partition int    `json:"partition"`
+	Replicas  []int  `json:"replicas"`
+}
+
+// assign assigns replicas of the partitions to the brokers in the same
+// way as Kafka does on topic creation but without randomness, so the
+// same input always produces the same plan. Leaders are assigned to
+// brokers in round-robin across all partitions and followers are
+// shifted every round so that replica pairs are spread as well. The
+// replication factor of each partition is kept.
+//
+// With racks, brokers are ordered so that racks alternate and replicas
+// of a partition are placed on distinct racks as long as there are
+// enough racks. When strict is true, it fails if the replication factor
+// of any partition is larger than the number of racks.
+func assign(partitions []*Partition, brokers []int, racks Racks, strict bool) ([]PartitionInfo, error) {
+	sorted, numRacks, err := prepare(partitions, brokers, racks, strict)
+	if err != nil {
+		return nil, err
+	}
+	n := len(sorted)
+
+	infos := make([]PartitionInfo, 0, len(partitions))
+	shift := 0
+	for i, p := range partitions {
+		if i > 0 && i%n == 0 {
+			shift++
+		}
+		first := i % n
+		leader := sorted[first]
+
+		replicas := []int{leader}
+		usedRacks := map[string]bool{racks.rack(leader): true}
+		usedBrokers := map[int]bool{leader: true}
+		// Candidates cycle through the other brokers, and a cycle after
+		// all racks are used accepts any broker which is not used yet
+		for k := 0; len(replicas) < len(p.Replicas) && k < 2*n; k++ {
+			b := sorted[replicaIndex(first, shift, k, n)]
+			if usedRacks[racks.rack(b)] && len(usedRacks) < numRacks {
+				continue
+			}
+			if usedBrokers[b] {
+				continue
+			}
+			replicas = append(replicas, b)
+			usedRacks[racks.rack(b)] = true
+			usedBrokers[b] = true
+		}
+		if len(replicas) < len(p.Replicas) {
+			return nil, fmt.Errorf("no broker left for replicas of %s", partitionKey(p.Topic, p.Partition))
+		}
+
+		infos = append(infos, PartitionInfo{
+			Topic:     p.Topic,
+			Partition: p.Partition,
+			Replicas:  replicas,
+		})
+	}
+	return infos, nil
+}
+
+// prepare validates the brokers and the partitions and returns the
+// brokers ordered so that racks alternate and the number of racks.
+func prepare(partitions []*Partition, brokers []int, racks Racks, strict bool) ([]int, int, error) {
+	if len(brokers) == 0 {
+		return nil, 0, fmt.Errorf("no target brokers")
+	}
+	seen := make(map[int]bool)
+	for _, b := range brokers {
+		if seen[b] {
+			return nil, 0, fmt.Errorf("duplicate broker ID %d", b)
+		}
+		seen[b] = true
+	}
+	if err := racks.check(brokers); err != nil {
+		return nil, 0, err
+	}
+
+	sorted := make([]int, len(brokers))
+	copy(sorted, brokers)
+	sort.Ints(sorted)
+	sorted = racks.alternate(sorted)
+
+	numRacks := racks.count(sorted)
+	if err := checkReplicationFactor(partitions, len(sorted), numRacks, racks != nil && strict); err != nil {
+		return nil, 0, err
+	}
+	return sorted, numRacks, nil
+}
+
+// assignBySize assigns replicas of the partitions to the brokers so that
+// disk usage is balanced. Partitions are placed from the largest one and
+// each replica goes to the broker with the least bytes (then the fewest
+// replicas) among ones on racks which the partition does not use yet.
+// The preferred leader is the replica which leads the fewest partitions
+// so far. Partitions which are not in sizes are regarded as defaultSize.
+// base is the disk usage of brokers by partitions out of the plan. Ties
+// are broken by the rack alternated order of brokers, so the same input
+// always produces the same plan.
+func assignBySize(partitions []*Partition, brokers []int, racks Racks, strict bool, sizes Sizes, defaultSize int64, base map[int]int64) ([]PartitionInfo, error) {
+	sorted, numRacks, err := prepare(partitions, brokers, racks, strict)
+	if err != nil {
+		return nil, err
+	}
+
+	bytes := make(map[int]int64)
+	for _, b := range sorted {
+		bytes[b] = base[b]
+	}
+	counts := make(map[int]int)
+	leaders := make(map[int]int)
+
+	order := make([]int, len(partitions))
+	for i := range order {
+		order[i] = i
+	}
+	sizeOf := func(p *Partition) int64 {
+		if v, ok := sizes.size(p.Topic, p.Partition); ok {
+			return v
+		}
+		return defaultSize
+	}
+	sort.SliceStable(order, func(i, j int) bool {
+		return sizeOf(partitions[order[i]]) > sizeOf(partitions[order[j]])
+	})
+
+	infos := make([]PartitionInfo, len(partitions))
+	for _, i := range order {
+		p := partitions[i]
+		size := sizeOf(p)
+
+		candidates := make([]int, len(sorted))
+		copy(candidates, sorted)
+		sort.SliceStable(candidates, func(i, j int) bool {
+			a, b := candidates[i], candidates[j]
+			if bytes[a] != bytes[b] {
+				return bytes[a] < bytes[b]
+			}
+			return counts[a] < counts[b]
+		})
+
+		var replicas []int
+		usedRacks := make(map[string]bool)
+		usedBrokers := make(map[int]bool)
+		for len(replicas) < len(p.Replicas) {
+			picked := false
+			for _, b := range candidates {
+				if usedBrokers[b] || (usedRacks[racks.rack(b)] && len(usedRacks) < numRacks) {
+					continue
+				}
+				replicas = append(replicas, b)
+				usedRacks[racks.rack(b)] = true
+				usedBrokers[b] = true
+				bytes[b] += size
+				counts[b]++
+				picked = true
+				break
+			}
+			if !picked {
+				return nil, fmt.Errorf("no broker left for replicas of %s", partitionKey(p.Topic, p.Partition))
+			}
+		}
+
+		// Move the replica which leads the fewest partitions to the front
+		l := 0
+		for j, b := range replicas {
+			if leaders[b] < leaders[replicas[l]] {
+				l = j
+			}
+		}
+		replicas[0], replicas[l] = replicas[l], replicas[0]
+		leaders[replicas[0]]++
+
+		infos[i] = PartitionInfo{
+			Topic:     p.Topic,
+			Partition: p.Partition,
+			Replicas:  replicas,
+		}
+	}
+	return infos, nil
+}
+
+// checkReplicationFactor returns an error when the replication factor
+// of a partition is larger than the number of brokers. When strict is
+// true, it also returns an error listing partitions whose replication
+// factor is larger than the number of racks. Otherwise they are logged
+// and some of their replicas share a rack.
+func checkReplicationFactor(partitions []*Partition, brokers, racks int, strict bool) error {
+	var report []string
+	for _, p := range partitions {
+		rf := len(p.Replicas)
+		if rf > brokers {
+			return fmt.Errorf("replication factor of %s-%d (%d) is larger than the number of brokers (%d)",
+				p.Topic, p.Partition, rf, brokers)
+		}
+		if rf > racks {
+			report = append(report, fmt.Sprintf("%s-%d (replication factor %d)", p.Topic, p.Partition, rf))
+		}
+	}
+
+	if len(report) == 0 {
+		return nil
+	}
+	if strict {
+		return fmt.Errorf("replication factor of %d partition(s) is larger than the number of racks (%d):\n  %s",
+			len(report), racks, strings.Join(report, "\n  "))
+	}
+	log.Printf("[WARN] Replicas of %d partition(s) share a rack because the replication factor is larger than the number of racks (%d)",
+		len(report), racks)
+	return nil
+}
+
+// replicaIndex returns the index of the k-th follower candidate whose
+// leader is at first (see AdminUtils.replicaIndex of Kafka).
+func replicaIndex(first, shift, k, n int) int {
+	s := 1 + (shift+k)%(n-1)
+	return (first + s) % n
+}
+
+// partitionKey returns the key of the partition like "topic1-0".
+func partitionKey(topic string, partition int) string {
+	return fmt.Sprintf("%s-%d", topic, partition)
+}
+
+// movedReplicas returns the number of replicas in the plan which are not
+// on the current brokers of the partition.
+func movedReplicas(current *Partition, info PartitionInfo) int {
+	if current == nil {
+		return len(info.Replicas)
+	}
+
+	n := 0
+	for _, b := range info.Replicas {
+		found := false
+		for _, c := range current.Replicas {
+			if b == c {
+				found = true
+				break
+			}
+		}
+		if !found {
+			n++
+		}
+	}
+	return n
+}
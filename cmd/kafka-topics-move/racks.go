@@ -0,0 +1,156 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Racks maps a broker ID to its rack (broker.rack).
+type Racks map[int]string
+
+var (
+	// reBrokerHeader is the header of kafka-configs.sh --describe
+	// --entity-type brokers output like "All configs for broker 1 are:"
+	// or "Configs for broker 1 are:".
+	reBrokerHeader = regexp.MustCompile(`[Cc]onfigs for broker (\d+) are`)
+
+	// reBrokerRack is broker.rack in kafka-configs.sh output.
+	reBrokerRack = regexp.MustCompile(`(?:^|[\s,])broker\.rack=([^\s,}]+)`)
+)
+
+// parseRacks parses the broker to rack mapping. Each line is a broker
+// ID and its rack separated by spaces or "=" (e.g., "1 us-east-1a").
+// Empty lines and lines starting with # are ignored. It also accepts
+// broker.rack in kafka-configs.sh --describe --entity-type brokers
+// --all output.
+func parseRacks(r io.Reader) (Racks, error) {
+	racks := make(Racks)
+
+	broker := -1
+	scnr := bufio.NewScanner(r)
+	for n := 1; scnr.Scan(); n++ {
+		line := strings.TrimSpace(scnr.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		if m := reBrokerHeader.FindStringSubmatch(line); m != nil {
+			broker, _ = strconv.Atoi(m[1])
+			continue
+		}
+		if m := reBrokerRack.FindStringSubmatch(line); m != nil {
+			if broker < 0 {
+				return nil, fmt.Errorf("line %d: broker.rack without broker", n)
+			}
+			// kafka-configs.sh prints null for brokers without
+			// broker.rack, which is left missing for check.
+			if _, ok := racks[broker]; !ok && m[1] != "null" {
+				racks[broker] = m[1]
+			}
+			continue
+		}
+		if broker >= 0 {
+			// Other configs of the broker
+			continue
+		}
+
+		fields := strings.Fields(strings.Replace(line, "=", " ", 1))
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("line %d: must be BROKER RACK: %q", n, line)
+		}
+		id, err := strconv.Atoi(fields[0])
+		if err != nil {
+			return nil, fmt.Errorf("line %d: invalid broker ID %q", n, fields[0])
+		}
+		racks[id] = fields[1]
+	}
+
+	if err := scnr.Err(); err != nil {
+		return nil, err
+	}
+	return racks, nil
+}
+
+// readRacks reads the broker to rack mapping file.
+func readRacks(path string) (Racks, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return parseRacks(f)
+}
+
+// rack returns the rack of the broker. Without the mapping, each broker
+// is regarded as its own rack.
+func (r Racks) rack(broker int) string {
+	if r == nil {
+		return strconv.Itoa(broker)
+	}
+	return r[broker]
+}
+
+// count returns the number of racks of the brokers.
+func (r Racks) count(brokers []int) int {
+	seen := make(map[string]bool)
+	for _, b := range brokers {
+		seen[r.rack(b)] = true
+	}
+	return len(seen)
+}
+
+// check returns an error when any of the brokers does not have a rack.
+// Like Kafka, racks must be given for all brokers or none.
+func (r Racks) check(brokers []int) error {
+	if r == nil {
+		return nil
+	}
+	var missing []string
+	for _, b := range brokers {
+		if _, ok := r[b]; !ok {
+			missing = append(missing, strconv.Itoa(b))
+		}
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("no rack for broker(s) %s", strings.Join(missing, ","))
+	}
+	return nil
+}
+
+// alternate returns the brokers ordered so that racks alternate (e.g.,
+// rack1: 1,4 rack2: 2,5 and rack3: 3 are ordered 1,2,3,4,5). Assigning
+// leaders in this order rotates them across racks.
+func (r Racks) alternate(brokers []int) []int {
+	byRack := make(map[string][]int)
+	var names []string
+	for _, b := range brokers {
+		name := r.rack(b)
+		if _, ok := byRack[name]; !ok {
+			names = append(names, name)
+		}
+		byRack[name] = append(byRack[name], b)
+	}
+	sort.Strings(names)
+	if r == nil {
+		// Keep the order of broker IDs rather than their names
+		sort.Slice(names, func(i, j int) bool {
+			return byRack[names[i]][0] < byRack[names[j]][0]
+		})
+	}
+
+	ordered := make([]int, 0, len(brokers))
+	for i := 0; len(ordered) < len(brokers); i++ {
+		for _, name := range names {
+			if bs := byRack[name]; i < len(bs) {
+				ordered = append(ordered, bs[i])
+			}
+		}
+	}
+	return ordered
+}
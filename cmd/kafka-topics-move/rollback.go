@@ -0,0 +1,85 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+)
+
+// changedPartitions returns partitions in the plan whose replicas (or
+// their order, i.e., the preferred leader) differ from the current ones.
+func changedPartitions(infos []PartitionInfo, current map[string]*Partition) []PartitionInfo {
+	var changed []PartitionInfo
+	for _, info := range infos {
+		p, ok := current[partitionKey(info.Topic, info.Partition)]
+		if ok && equalReplicas(p.Replicas, info.Replicas) {
+			continue
+		}
+		changed = append(changed, info)
+	}
+	return changed
+}
+
+func equalReplicas(a, b []int) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// rollbackPlan returns the reassignment which restores the current
+// replicas of the partitions in the plan.
+func rollbackPlan(infos []PartitionInfo, current map[string]*Partition) (*Reassignment, error) {
+	r := &Reassignment{Version: defaultVersion}
+	for _, info := range infos {
+		p, ok := current[partitionKey(info.Topic, info.Partition)]
+		if !ok {
+			return nil, fmt.Errorf("no current assignment of %s", partitionKey(info.Topic, info.Partition))
+		}
+		r.Partitions = append(r.Partitions, PartitionInfo{
+			Topic:     p.Topic,
+			Partition: p.Partition,
+			Replicas:  p.Replicas,
+		})
+	}
+	return r, nil
+}
+
+// checksum returns the short checksum of the plan. It is put in names
+// of the plan and the rollback files to tie them together.
+func checksum(r *Reassignment) (string, error) {
+	buf, err := json.Marshal(r)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(buf)
+	return hex.EncodeToString(sum[:])[:8], nil
+}
+
+// rollbackFile returns the file name of the rollback of the plan.
+func rollbackFile(sum string) string {
+	return fmt.Sprintf("rollback-%s.json", sum)
+}
+
+// writeReassignment writes the reassignment as indented JSON.
+func writeReassignment(path string, r *Reassignment) error {
+	buf, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, append(buf, '\n'), 0644)
+}
+
+// writeRollback writes the rollback of the plan to the directory.
+func writeRollback(dir, sum string, r *Reassignment) (string, error) {
+	path := filepath.Join(dir, rollbackFile(sum))
+	return path, writeReassignment(path, r)
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"log"
+	"sort"
+)
+
+// TopicSummary is the data movement of a topic in the plan.
+type TopicSummary struct {
+	Topic      string
+	Partitions int
+
+	// Replicas is the number of replicas moved by the topic.
+	Replicas int
+
+	// Bytes is the estimated bytes moved by the topic.
+	Bytes int64
+
+	// Largest is the largest partition of the topic and its size.
+	Largest     int
+	LargestSize int64
+}
+
+// summarize returns the data movement per topic ordered from the one
+// which moves the most bytes. replicas returns the number of replicas
+// moved by the partition and size returns the size of the partition.
+func summarize(infos []PartitionInfo, replicas func(PartitionInfo) int, size func(PartitionInfo) int64) []*TopicSummary {
+	var summaries []*TopicSummary
+	index := make(map[string]*TopicSummary)
+	for _, info := range infos {
+		s, ok := index[info.Topic]
+		if !ok {
+			s = &TopicSummary{Topic: info.Topic, Largest: -1}
+			index[info.Topic] = s
+			summaries = append(summaries, s)
+		}
+
+		n, v := replicas(info), size(info)
+		s.Partitions++
+		s.Replicas += n
+		s.Bytes += int64(n) * v
+		if s.Largest < 0 || v > s.LargestSize {
+			s.Largest, s.LargestSize = info.Partition, v
+		}
+	}
+
+	sort.SliceStable(summaries, func(i, j int) bool {
+		return summaries[i].Bytes > summaries[j].Bytes
+	})
+	return summaries
+}
+
+// logSummary logs the data movement per topic. Topics which have a
+// partition larger than huge are logged as warnings to be spotted easily.
+// Without sizes only the partitions and replicas are logged since the
+// bytes would be guessed from -partition-size.
+func logSummary(summaries []*TopicSummary, huge int64, sized bool) {
+	if !sized {
+		var total int
+		for _, s := range summaries {
+			total += s.Replicas
+			log.Printf("[INFO] %s: %d partitions, %d replicas to move",
+				s.Topic, s.Partitions, s.Replicas)
+		}
+		log.Printf("[INFO] Total: %d replicas to move", total)
+		return
+	}
+
+	var total int64
+	for _, s := range summaries {
+		total += s.Bytes
+		if huge > 0 && s.LargestSize >= huge {
+			log.Printf("[WARN] %s: %d partitions, %s to move, HUGE partition %s (%s)",
+				s.Topic, s.Partitions, formatBytes(s.Bytes),
+				partitionKey(s.Topic, s.Largest), formatBytes(s.LargestSize))
+			continue
+		}
+		log.Printf("[INFO] %s: %d partitions, %s to move, largest partition %s (%s)",
+			s.Topic, s.Partitions, formatBytes(s.Bytes),
+			partitionKey(s.Topic, s.Largest), formatBytes(s.LargestSize))
+	}
+	log.Printf("[INFO] Total: %s to move", formatBytes(total))
+}